@@ -47,16 +47,40 @@ func CreateIndexes(client *mongo.Client) {
 	taskIndexes := []mongo.IndexModel{
 		{Keys: bson.D{{Key: "projectid", Value: 1}}},
 		{Keys: bson.D{{Key: "assignedto", Value: 1}}},
+		// Board order: tasks of a column sorted by rank
+		{Keys: bson.D{{Key: "projectid", Value: 1}, {Key: "columnId", Value: 1}, {Key: "rank", Value: 1}}},
+		// Task lists: one per sort option, with _id as the paging tie-breaker
+		{Keys: bson.D{{Key: "projectid", Value: 1}, {Key: "rank", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "projectid", Value: 1}, {Key: "duedate", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "projectid", Value: 1}, {Key: "updatedat", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "projectid", Value: 1}, {Key: "createdat", Value: 1}, {Key: "_id", Value: 1}}},
+		// ...and for the common filters
+		{Keys: bson.D{{Key: "projectid", Value: 1}, {Key: "status", Value: 1}, {Key: "rank", Value: 1}}},
+		{Keys: bson.D{{Key: "projectid", Value: 1}, {Key: "assignedto", Value: 1}, {Key: "duedate", Value: 1}}},
+		{Keys: bson.D{{Key: "projectid", Value: 1}, {Key: "priority", Value: 1}, {Key: "duedate", Value: 1}}},
+		// Subtasks of a task, with their status for progress rollups
+		{Keys: bson.D{{Key: "parentId", Value: 1}, {Key: "status", Value: 1}}},
+		// Label filters, and removing a deleted label from its tasks
+		{Keys: bson.D{{Key: "projectid", Value: 1}, {Key: "labels", Value: 1}}},
+		// Custom field filters and sorts; the paths differ per field
+		{Keys: bson.D{{Key: "customFields.$**", Value: 1}}},
+	}
+	if _, err := taskColl.Indexes().CreateMany(ctx, taskIndexes); err != nil {
+		fmt.Println("Could not create task indexes:", err)
 	}
-	taskColl.Indexes().CreateMany(ctx, taskIndexes)
 
 	// 2. Users Collection Indexes
 	userColl := GetCollection(client, "users")
-	userIndex := mongo.IndexModel{
-		Keys:    bson.D{{Key: "email", Value: 1}},
-		Options: options.Index().SetUnique(true),
+	userIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+		// User list sorts
+		{Keys: bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "role", Value: 1}, {Key: "createdAt", Value: 1}}},
+	}
+	if _, err := userColl.Indexes().CreateMany(ctx, userIndexes); err != nil {
+		fmt.Println("Could not create user indexes:", err)
 	}
-	userColl.Indexes().CreateOne(ctx, userIndex)
 
 	// 3. Projects Collection Indexes (ADDED)
 	projColl := GetCollection(client, "projects")
@@ -65,15 +89,100 @@ func CreateIndexes(client *mongo.Client) {
 		{Keys: bson.D{{Key: "ownerId", Value: 1}}},
 		// Speeds up finding projects where you are a member (Multikey Index)
 		{Keys: bson.D{{Key: "memberIds", Value: 1}}},
+		{Keys: bson.D{{Key: "memberships.userId", Value: 1}}},
+		// Project list, newest or oldest first
+		{Keys: bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}},
 	}
 	_, err := projColl.Indexes().CreateMany(ctx, projIndexes)
 	if err != nil {
 		fmt.Println("Could not create project indexes:", err)
 	}
 
-	fmt.Println("Database Indexes verified/created for Users, Tasks, and Projects.")
+	// 4. Token Collections: look up a user's sessions, and let Mongo expire old entries
+	refreshColl := GetCollection(client, "refresh_tokens")
+	refreshIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userId", Value: 1}}},
+		{Keys: bson.D{{Key: "familyId", Value: 1}}},
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}
+	if _, err := refreshColl.Indexes().CreateMany(ctx, refreshIndexes); err != nil {
+		fmt.Println("Could not create refresh token indexes:", err)
+	}
+
+	revokedColl := GetCollection(client, "revoked_tokens")
+	revokedIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+	if _, err := revokedColl.Indexes().CreateOne(ctx, revokedIndex); err != nil {
+		fmt.Println("Could not create revoked token indexes:", err)
+	}
+
+	// 5. API Keys: looked up by their public prefix on every request
+	keyColl := GetCollection(client, "api_keys")
+	keyIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "prefix", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "ownerId", Value: 1}}},
+	}
+	if _, err := keyColl.Indexes().CreateMany(ctx, keyIndexes); err != nil {
+		fmt.Println("Could not create API key indexes:", err)
+	}
+
+	// 6. Invitations: listed per project, matched by invitee email
+	inviteColl := GetCollection(client, "invitations")
+	inviteIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "projectId", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "email", Value: 1}}},
+	}
+	if _, err := inviteColl.Indexes().CreateMany(ctx, inviteIndexes); err != nil {
+		fmt.Println("Could not create invitation indexes:", err)
+	}
+
+	// 7. Comments: listed per task in order, removed with their project
+	commentColl := GetCollection(client, "comments")
+	commentIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "taskId", Value: 1}, {Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{{Key: "parentId", Value: 1}}},
+		{Keys: bson.D{{Key: "projectId", Value: 1}}},
+	}
+	if _, err := commentColl.Indexes().CreateMany(ctx, commentIndexes); err != nil {
+		fmt.Println("Could not create comment indexes:", err)
+	}
+
+	// 8. Task links: one per pair and type, walked in both directions
+	linkColl := GetCollection(client, "task_links")
+	linkIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "fromTaskId", Value: 1}, {Key: "toTaskId", Value: 1}, {Key: "type", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "toTaskId", Value: 1}, {Key: "type", Value: 1}}},
+		{Keys: bson.D{{Key: "fromProjectId", Value: 1}}},
+		{Keys: bson.D{{Key: "toProjectId", Value: 1}}},
+	}
+	if _, err := linkColl.Indexes().CreateMany(ctx, linkIndexes); err != nil {
+		fmt.Println("Could not create task link indexes:", err)
+	}
+
+	fmt.Println("Database Indexes verified/created for Users, Tasks, Projects, Tokens, API Keys, Invitations, Comments, and Task Links.")
 }
 
 func GetCollection(client *mongo.Client, collectionName string) *mongo.Collection {
 	return client.Database("Trello_lite").Collection(collectionName)
 }
+
+// WithTransaction runs fn inside a multi-document transaction, retrying on
+// transient errors. MongoDB only supports transactions on replica sets, so a
+// standalone server needs to be started as a single-node replica set.
+func WithTransaction(ctx context.Context, fn func(sc mongo.SessionContext) error) error {
+	session, err := Client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+	"trello-lite/databases"
+	"trello-lite/models"
+	"trello-lite/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// defaultAPIKeyDays applies when expiresInDays is left out; the maximum of
+// 365 is enforced by the request's validate tag
+const defaultAPIKeyDays = 90
+
+// isAdmin reports whether the caller holds a global admin role
+func isAdmin(r *http.Request) bool {
+	role := r.Header.Get("Role")
+	return role == models.RoleAdmin || role == models.RoleSuperAdmin
+}
+
+func CreateServiceAccountHandler(w http.ResponseWriter, r *http.Request) {
+	if !isAdmin(r) {
+		utils.SendError(w, http.StatusForbidden, "Access denied: Admin privileges required")
+		return
+	}
+
+	var request struct {
+		Name string `json:"name" validate:"required,max=100"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.Validate(request); len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	// Service accounts have no password, so they can never log in interactively.
+	// They still need a unique email because of the users.email index.
+	id := primitive.NewObjectID().Hex()
+	account := models.User{
+		ID:        id,
+		Name:      request.Name,
+		Email:     id + "@service-accounts.trello-lite",
+		Role:      models.RoleUser,
+		Type:      models.UserTypeService,
+		CreatedAt: time.Now(),
+	}
+
+	collection := databases.GetCollection(databases.Client, "users")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := collection.InsertOne(ctx, account); err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	utils.SendCreated(w, "Service account created", account)
+}
+
+func CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Name          string   `json:"name" validate:"required,max=100"`
+		Scopes        []string `json:"scopes" validate:"required"`
+		ExpiresInDays int      `json:"expiresInDays" validate:"min=1,max=365"`
+		OwnerID       string   `json:"ownerId"` // admins only: mint a key for a service account
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// 1. Validate the request
+	if request.ExpiresInDays == 0 {
+		request.ExpiresInDays = defaultAPIKeyDays
+	}
+	errs := utils.Validate(request)
+	for i, scope := range request.Scopes {
+		if !models.IsValidScope(scope) {
+			errs.Add(fmt.Sprintf("scopes[%d]", i), utils.CodeInvalidChoice, "Unknown scope: "+scope)
+		}
+	}
+	if len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 2. Users mint keys for themselves; admins may also mint for service accounts
+	callerID := r.Header.Get("User-ID")
+	ownerID := callerID
+	if request.OwnerID != "" && request.OwnerID != callerID {
+		if !isAdmin(r) {
+			utils.SendError(w, http.StatusForbidden, "Only admins can create keys for other accounts")
+			return
+		}
+		var owner models.User
+		userColl := databases.GetCollection(databases.Client, "users")
+		if err := userColl.FindOne(ctx, bson.M{"_id": request.OwnerID}).Decode(&owner); err != nil {
+			utils.SendError(w, http.StatusNotFound, "Owner not found")
+			return
+		}
+		if owner.Type != models.UserTypeService {
+			utils.SendError(w, http.StatusForbidden, "Keys can only be created for yourself or a service account")
+			return
+		}
+		ownerID = owner.ID
+	}
+
+	// 3. Generate and store the hashed key
+	rawKey, lookup, err := utils.NewAPIKey()
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Error generating key")
+		return
+	}
+
+	now := time.Now()
+	key := models.APIKey{
+		ID:        primitive.NewObjectID().Hex(),
+		Name:      request.Name,
+		OwnerID:   ownerID,
+		CreatedBy: callerID,
+		Prefix:    lookup,
+		Hash:      utils.HashToken(rawKey),
+		Scopes:    request.Scopes,
+		CreatedAt: now,
+		ExpiresAt: now.AddDate(0, 0, request.ExpiresInDays),
+	}
+
+	collection := databases.GetCollection(databases.Client, "api_keys")
+	if _, err := collection.InsertOne(ctx, key); err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// 4. The raw key is returned exactly once
+	utils.SendCreated(w, "API key created. Store it now, it will not be shown again.", map[string]interface{}{
+		"key":    rawKey,
+		"apiKey": key,
+	})
+}
+
+func ListAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	// Users see their own keys; admins can see anyone's with ?ownerId=, or every key
+	filter := bson.M{"ownerId": r.Header.Get("User-ID")}
+	if isAdmin(r) {
+		filter = bson.M{}
+		if ownerID := r.URL.Query().Get("ownerId"); ownerID != "" {
+			filter["ownerId"] = ownerID
+		}
+	}
+
+	collection := databases.GetCollection(databases.Client, "api_keys")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Failed to query API keys")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	keys := []models.APIKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Error parsing API keys")
+		return
+	}
+
+	utils.SendSuccess(w, "API keys retrieved successfully", keys)
+}
+
+func RevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ID string `json:"id"`
+	}
+	if err := decodeOptionalBody(r, &request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	request.ID = pathParam(r, "keyId", request.ID)
+	if request.ID == "" {
+		utils.SendError(w, http.StatusBadRequest, "id is required")
+		return
+	}
+
+	// Owners revoke their own keys; admins can revoke any key
+	filter := bson.M{"_id": request.ID, "revokedAt": nil}
+	if !isAdmin(r) {
+		filter["ownerId"] = r.Header.Get("User-ID")
+	}
+
+	collection := databases.GetCollection(databases.Client, "api_keys")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"revokedAt": time.Now()}})
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if result.MatchedCount == 0 {
+		utils.SendError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+
+	utils.SendSuccess(w, "API key revoked", nil)
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+	"trello-lite/databases"
+	"trello-lite/models"
+	"trello-lite/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// issueTokens creates an access token and a new refresh token in the given
+// family. An empty familyID starts a new family (a fresh login).
+func issueTokens(ctx context.Context, user models.User, familyID string) (map[string]interface{}, error) {
+	accessToken, err := utils.GenerateJWT(user.ID, user.Role, user.TokenVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := utils.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if familyID == "" {
+		familyID = primitive.NewObjectID().Hex()
+	}
+
+	now := time.Now()
+	stored := models.RefreshToken{
+		ID:           utils.HashToken(refreshToken),
+		UserID:       user.ID,
+		FamilyID:     familyID,
+		TokenVersion: user.TokenVersion,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(utils.RefreshTokenTTL),
+	}
+
+	collection := databases.GetCollection(databases.Client, "refresh_tokens")
+	if _, err := collection.InsertOne(ctx, stored); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"token":        accessToken,
+		"expiresIn":    int(utils.AccessTokenTTL.Seconds()),
+		"refreshToken": refreshToken,
+		"role":         user.Role,
+		"id":           user.ID,
+	}, nil
+}
+
+func RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.RefreshToken == "" {
+		utils.SendError(w, http.StatusBadRequest, "refreshToken is required")
+		return
+	}
+
+	tokenColl := databases.GetCollection(databases.Client, "refresh_tokens")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 1. Atomically consume the token so two concurrent refreshes cannot both succeed
+	now := time.Now()
+	hash := utils.HashToken(request.RefreshToken)
+	var stored models.RefreshToken
+	err := tokenColl.FindOneAndUpdate(ctx,
+		bson.M{"_id": hash, "revokedAt": nil, "expiresAt": bson.M{"$gt": now}},
+		bson.M{"$set": bson.M{"revokedAt": now}},
+	).Decode(&stored)
+
+	if err == mongo.ErrNoDocuments {
+		// 2. Reuse of an already rotated token means it leaked: kill the whole family
+		var reused models.RefreshToken
+		if tokenColl.FindOne(ctx, bson.M{"_id": hash}).Decode(&reused) == nil && reused.RevokedAt != nil {
+			revokeRefreshTokens(ctx, bson.M{"familyId": reused.FamilyID})
+		}
+		utils.SendError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+		return
+	}
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// 3. The user must still exist and must not have been logged out everywhere since
+	var user models.User
+	userColl := databases.GetCollection(databases.Client, "users")
+	if err := userColl.FindOne(ctx, bson.M{"_id": stored.UserID}).Decode(&user); err != nil || user.TokenVersion != stored.TokenVersion {
+		utils.SendError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+		return
+	}
+
+	tokens, err := issueTokens(ctx, user, stored.FamilyID)
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Error generating token")
+		return
+	}
+
+	utils.SendSuccess(w, "Token refreshed", tokens)
+}
+
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	// The refresh token is optional; without it only the access token is revoked
+	var request struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	json.NewDecoder(r.Body).Decode(&request)
+
+	userID := r.Header.Get("User-ID")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 1. Denylist the access token used for this request until it expires
+	expires, _ := strconv.ParseInt(r.Header.Get("Token-Expires"), 10, 64)
+	revoked := models.RevokedToken{
+		ID:        r.Header.Get("Token-ID"),
+		UserID:    userID,
+		ExpiresAt: time.Unix(expires, 0),
+	}
+	revokedColl := databases.GetCollection(databases.Client, "revoked_tokens")
+	_, err := revokedColl.UpdateOne(ctx,
+		bson.M{"_id": revoked.ID},
+		bson.M{"$setOnInsert": revoked},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// 2. Revoke the session's refresh token family
+	if request.RefreshToken != "" {
+		var stored models.RefreshToken
+		tokenColl := databases.GetCollection(databases.Client, "refresh_tokens")
+		filter := bson.M{"_id": utils.HashToken(request.RefreshToken), "userId": userID}
+		if tokenColl.FindOne(ctx, filter).Decode(&stored) == nil {
+			revokeRefreshTokens(ctx, bson.M{"familyId": stored.FamilyID})
+		}
+	}
+
+	utils.SendSuccess(w, "Logged out", nil)
+}
+
+func LogoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("User-ID")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Bumping the version invalidates every access token; refresh tokens are revoked explicitly
+	userColl := databases.GetCollection(databases.Client, "users")
+	_, err := userColl.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$inc": bson.M{"tokenVersion": 1}})
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if err := revokeRefreshTokens(ctx, bson.M{"userId": userID}); err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	utils.SendSuccess(w, "Logged out of all sessions", nil)
+}
+
+// revokeRefreshTokens marks every still-active refresh token matching filter as revoked
+func revokeRefreshTokens(ctx context.Context, filter bson.M) error {
+	filter["revokedAt"] = nil
+	collection := databases.GetCollection(databases.Client, "refresh_tokens")
+	_, err := collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revokedAt": time.Now()}})
+	return err
+}
+
+// JWKSHandler publishes the public signing keys so other services can verify
+// our tokens without sharing a secret. It uses the standard JWKS shape rather
+// than the APIResponse envelope because JWT libraries consume it directly.
+func JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(utils.JWKS())
+}
@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+	"trello-lite/databases"
+	"trello-lite/models"
+	"trello-lite/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// boardColumn is one column of the board view with its tasks in rank order
+type boardColumn struct {
+	models.Column
+	Tasks []models.Task `json:"tasks"`
+}
+
+// nextRankInColumn returns the rank of the first task in the column after afterRank
+// (excluding the task being moved), or "" if there is none
+func nextRankInColumn(ctx context.Context, projectID, columnID, afterRank, excludeID string) (string, error) {
+	collection := databases.GetCollection(databases.Client, "tasks")
+	filter := bson.M{
+		"projectid": projectID,
+		"columnId":  columnID,
+		"rank":      bson.M{"$gt": afterRank},
+		"_id":       bson.M{"$ne": excludeID},
+	}
+	opts := options.FindOne().SetSort(bson.D{{Key: "rank", Value: 1}}).SetProjection(bson.M{"rank": 1})
+
+	var next models.Task
+	err := collection.FindOne(ctx, filter, opts).Decode(&next)
+	if err == mongo.ErrNoDocuments {
+		return "", nil
+	}
+	return next.Rank, err
+}
+
+// rankAtEnd returns a rank that places a task at the bottom of a column
+func rankAtEnd(ctx context.Context, projectID, columnID string) (string, error) {
+	collection := databases.GetCollection(databases.Client, "tasks")
+	opts := options.FindOne().SetSort(bson.D{{Key: "rank", Value: -1}}).SetProjection(bson.M{"rank": 1})
+
+	var last models.Task
+	err := collection.FindOne(ctx, bson.M{"projectid": projectID, "columnId": columnID}, opts).Decode(&last)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return "", err
+	}
+	return utils.RankBetween(last.Rank, "")
+}
+
+// columnForStatus picks the column a new task lands in: the first one mapped
+// to its status, otherwise the first column of the board
+func columnForStatus(project models.Project, status string) (models.Column, bool) {
+	for _, c := range project.Columns {
+		if c.Status != "" && c.Status == status {
+			return c, true
+		}
+	}
+	if len(project.Columns) > 0 {
+		return project.Columns[0], true
+	}
+	return models.Column{}, false
+}
+
+// syncColumnForStatus keeps the board in step with a status change: if the task
+// sits in a column bound to another status, it is moved to the bottom of the
+// column bound to the new one. The column and rank are added to set.
+func syncColumnForStatus(ctx context.Context, project models.Project, task models.Task, status string, set bson.M) error {
+	if current, ok := project.Column(task.ColumnID); ok && (current.Status == "" || current.Status == status) {
+		return nil
+	}
+	for _, c := range project.Columns {
+		if c.Status == status {
+			rank, err := rankAtEnd(ctx, project.ID, c.ID)
+			if err != nil {
+				return err
+			}
+			set["columnId"] = c.ID
+			set["rank"] = rank
+			return nil
+		}
+	}
+	return nil
+}
+
+// groupTasksByColumn arranges rank-sorted tasks into the board's columns.
+// Tasks whose column no longer exists are collected in a trailing unnamed column.
+func groupTasksByColumn(project models.Project, tasks []models.Task) []boardColumn {
+	board := make([]boardColumn, 0, len(project.Columns)+1)
+	index := map[string]int{}
+	for i, c := range project.Columns {
+		board = append(board, boardColumn{Column: c, Tasks: []models.Task{}})
+		index[c.ID] = i
+	}
+
+	unsorted := boardColumn{Column: models.Column{Name: "No column"}, Tasks: []models.Task{}}
+	for _, t := range tasks {
+		if i, ok := index[t.ColumnID]; ok {
+			board[i].Tasks = append(board[i].Tasks, t)
+		} else {
+			unsorted.Tasks = append(unsorted.Tasks, t)
+		}
+	}
+	if len(unsorted.Tasks) > 0 {
+		board = append(board, unsorted)
+	}
+	return board
+}
+
+func UpdateColumnsHandler(w http.ResponseWriter, r *http.Request) {
+	// The request carries the complete ordered column list: add, rename,
+	// reorder and remove are all expressed by sending the new list
+	var request struct {
+		ProjectID string          `json:"projectId" validate:"required"`
+		Columns   []models.Column `json:"columns" validate:"required,dive"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	request.ProjectID = pathParam(r, "projectId", request.ProjectID)
+	errs := utils.Validate(request)
+	if len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	project, _, ok := authorizeProject(ctx, w, r, request.ProjectID, models.ProjectRoleMaintainer)
+	if !ok {
+		return
+	}
+
+	// 1. Check statuses against the workflow and assign IDs to new columns
+	seen := map[string]bool{}
+	workflow := project.EffectiveWorkflow()
+	for i := range request.Columns {
+		c := &request.Columns[i]
+		if c.Status != "" && !workflow.HasStatus(c.Status) {
+			errs.Add(fmt.Sprintf("columns[%d].status", i), utils.CodeInvalidChoice, "Column \""+c.Name+"\" uses unknown status \""+c.Status+"\"")
+		}
+		if c.ID == "" {
+			c.ID = primitive.NewObjectID().Hex()
+		}
+		if seen[c.ID] {
+			errs.Add(fmt.Sprintf("columns[%d].id", i), utils.CodeConflict, "Duplicate column id "+c.ID)
+		}
+		seen[c.ID] = true
+	}
+	if len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	// 2. A column can only be removed once it is empty
+	taskColl := databases.GetCollection(databases.Client, "tasks")
+	for _, old := range project.Columns {
+		if seen[old.ID] {
+			continue
+		}
+		count, err := taskColl.CountDocuments(ctx, bson.M{"projectid": project.ID, "columnId": old.ID})
+		if err != nil {
+			utils.SendError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if count > 0 {
+			utils.SendError(w, http.StatusConflict, "Column \""+old.Name+"\" still has tasks; move them first")
+			return
+		}
+	}
+
+	collection := databases.GetCollection(databases.Client, "projects")
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": project.ID}, bson.M{"$set": bson.M{
+		"columns":   request.Columns,
+		"updatedAt": time.Now(),
+	}})
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	utils.SendSuccess(w, "Columns updated", request.Columns)
+}
+
+func MoveTaskHandler(w http.ResponseWriter, r *http.Request) {
+	// afterId is the task the moved task should sit directly below; empty means top of the column
+	var request struct {
+		ID       string `json:"id"`
+		ColumnID string `json:"columnId"`
+		AfterID  string `json:"afterId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	request.ID = pathParam(r, "taskId", request.ID)
+
+	userID := r.Header.Get("User-ID")
+	collection := databases.GetCollection(databases.Client, "tasks")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 1. Same permissions as a status change
+	task, project, role, ok := authorizeTask(ctx, w, r, request.ID, models.ProjectRoleMember)
+	if !ok {
+		return
+	}
+	if role == models.ProjectRoleMember && task.AssignedTo != userID {
+		utils.SendError(w, http.StatusForbidden, "Members can only move tasks assigned to them")
+		return
+	}
+
+	if !checkIfMatch(w, r, task) {
+		return
+	}
+
+	var errs utils.ValidationErrors
+	column, ok := project.Column(request.ColumnID)
+	if !ok {
+		errs.Add("columnId", utils.CodeNotFound, "Unknown columnId")
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	// 2. Dropping on a status-bound column is a status change and follows the workflow
+	var blocked []string
+	if column.Status != "" {
+		if code, msg := checkTransition(project, role, task.Status, column.Status); code != 0 {
+			sendTransitionError(w, code, msg)
+			return
+		}
+		if code, msg := checkChildrenDone(ctx, project, task, column.Status); code != 0 {
+			sendChildrenError(w, code, msg)
+			return
+		}
+		var code int
+		var msg string
+		if blocked, code, msg = checkBlockers(ctx, project, task, column.Status); code != 0 {
+			sendBlockersError(w, code, msg)
+			return
+		}
+	}
+
+	// 3. Work out the rank between the new neighbours
+	prevRank := ""
+	if request.AfterID != "" {
+		if request.AfterID == task.ID {
+			errs.Add("afterId", utils.CodeNotAllowed, "A task cannot be placed after itself")
+			utils.SendValidationErrors(w, errs)
+			return
+		}
+		var after models.Task
+		filter := taskIDFilter(request.AfterID)
+		filter["projectid"] = project.ID
+		filter["columnId"] = column.ID
+		if err := collection.FindOne(ctx, filter).Decode(&after); err != nil {
+			errs.Add("afterId", utils.CodeNotFound, "afterId is not a task in that column")
+			utils.SendValidationErrors(w, errs)
+			return
+		}
+		prevRank = after.Rank
+	}
+
+	nextRank, err := nextRankInColumn(ctx, project.ID, column.ID, prevRank, task.ID)
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	rank, err := utils.RankBetween(prevRank, nextRank)
+	if err != nil {
+		// Two neighbours share a rank (concurrent moves); sort after the earlier one
+		if rank, err = utils.RankBetween(prevRank, ""); err != nil {
+			log.Printf("[%s] rank between %q and %q: %v", r.Header.Get(utils.RequestIDHeader), prevRank, nextRank, err)
+			utils.SendError(w, http.StatusInternalServerError, "Could not place the task")
+			return
+		}
+	}
+
+	// 4. A single document write changes both column and position atomically
+	// (WIP limits are checked in the same transaction as the write)
+	set := bson.M{"columnId": column.ID, "rank": rank, "updatedat": time.Now()}
+	status := task.Status
+	if column.Status != "" {
+		set["status"] = column.Status
+		status = column.Status
+	}
+	warnings, result, err := updateTaskWithWIP(ctx, project, task, status, task.AssignedTo, taskVersionFilter(task), bumpVersion(bson.M{"$set": set}))
+	if sendWIPError(w, err) {
+		return
+	}
+	if result.MatchedCount == 0 {
+		sendVersionConflict(w)
+		return
+	}
+
+	setTaskETag(w, task.Version+1)
+	set["version"] = task.Version + 1
+	set["warnings"] = append(warnings, blocked...)
+	utils.SendSuccess(w, "Task moved", set)
+}
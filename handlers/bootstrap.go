@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"trello-lite/databases"
+	"trello-lite/models"
+	"trello-lite/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// BootstrapSuperAdmin creates the initial Super Admin, or promotes an existing
+// account with that email. It is driven by command line flags because
+// self-signup can never grant elevated roles.
+func BootstrapSuperAdmin(name, email, password string) error {
+	if email == "" {
+		return errors.New("bootstrap admin email is required")
+	}
+
+	collection := databases.GetCollection(databases.Client, "users")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var hash string
+	set := bson.M{"role": models.RoleSuperAdmin}
+	if password != "" {
+		if err := utils.ValidatePassword(password); err != nil {
+			return err
+		}
+		var err error
+		if hash, err = utils.HashPassword(password); err != nil {
+			return err
+		}
+		set["passwordHash"] = hash
+	}
+
+	var existing models.User
+	err := collection.FindOne(ctx, bson.M{"email": email}).Decode(&existing)
+	if err == nil {
+		// Promoting changes the role, so old tokens must go
+		_, err = collection.UpdateOne(ctx, bson.M{"_id": existing.ID}, bson.M{
+			"$set": set,
+			"$inc": bson.M{"tokenVersion": 1},
+		})
+		if err == nil {
+			fmt.Println("Bootstrap: promoted", email, "to Super Admin")
+		}
+		return err
+	}
+	if err != mongo.ErrNoDocuments {
+		return err
+	}
+
+	if password == "" {
+		return errors.New("bootstrap admin password is required to create a new account")
+	}
+
+	admin := models.User{
+		ID:           primitive.NewObjectID().Hex(),
+		Name:         name,
+		Email:        email,
+		Role:         models.RoleSuperAdmin,
+		PasswordHash: hash,
+		CreatedAt:    time.Now(),
+	}
+	if _, err := collection.InsertOne(ctx, admin); err != nil {
+		return err
+	}
+
+	fmt.Println("Bootstrap: created Super Admin", email)
+	return nil
+}
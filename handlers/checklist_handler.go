@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+	"trello-lite/databases"
+	"trello-lite/models"
+	"trello-lite/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Checklist changes are task writes: plain members may only make them on tasks
+// assigned to them, they honour If-Match and bump the task version like every
+// other task update.
+
+// checklistItemRequest is the body of the add and update endpoints. Fields
+// left out of an update keep their value; an empty dueDate or assignedTo clears it.
+type checklistItemRequest struct {
+	Text       *string `json:"text"`
+	Done       *bool   `json:"done"`
+	AssignedTo *string `json:"assignedTo"`
+	DueDate    *string `json:"dueDate"`
+}
+
+// apply copies the request onto item, reporting invalid fields in errs
+func (req checklistItemRequest) apply(project models.Project, item *models.ChecklistItem, errs *utils.ValidationErrors) {
+	if req.Text != nil {
+		item.Text = *req.Text
+	}
+	if req.Done != nil {
+		item.Done = *req.Done
+	}
+	if req.AssignedTo != nil {
+		item.AssignedTo = *req.AssignedTo
+		if item.AssignedTo != "" && project.RoleOf(item.AssignedTo) == "" {
+			errs.Add("assignedTo", utils.CodeNotAllowed, "assignedTo must be a member of the project")
+		}
+	}
+	if req.DueDate != nil {
+		item.DueDate = nil
+		if *req.DueDate != "" {
+			if due, ok := parseDateParam(*req.DueDate); ok {
+				item.DueDate = &due
+			} else {
+				errs.Add("dueDate", utils.CodeInvalidFormat, "dueDate must be a date (YYYY-MM-DD) or an RFC 3339 time")
+			}
+		}
+	}
+	*errs = append(*errs, utils.Validate(item)...)
+}
+
+// loadChecklistTask authorizes a checklist change on the {taskId} of the path
+func loadChecklistTask(ctx context.Context, w http.ResponseWriter, r *http.Request) (models.Task, models.Project, bool) {
+	task, project, role, ok := authorizeTask(ctx, w, r, r.PathValue("taskId"), models.ProjectRoleMember)
+	if !ok {
+		return task, project, false
+	}
+	if role == models.ProjectRoleMember && task.AssignedTo != r.Header.Get("User-ID") {
+		utils.SendError(w, http.StatusForbidden, "Members can only update tasks assigned to them")
+		return task, project, false
+	}
+	if !checkIfMatch(w, r, task) {
+		return task, project, false
+	}
+	return task, project, true
+}
+
+// saveChecklist writes the whole checklist back, only if the task is still at
+// the version it was read at, and responds with the new checklist
+func saveChecklist(ctx context.Context, w http.ResponseWriter, r *http.Request, task models.Task, checklist []models.ChecklistItem, desc string) {
+	update := bumpVersion(bson.M{"$set": bson.M{"checklist": checklist, "updatedat": time.Now()}})
+	result, err := databases.GetCollection(databases.Client, "tasks").UpdateOne(ctx, taskVersionFilter(task), update)
+	if err != nil {
+		log.Printf("[%s] update checklist: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if result.MatchedCount == 0 {
+		sendVersionConflict(w)
+		return
+	}
+
+	setTaskETag(w, task.Version+1)
+	utils.SendSuccess(w, desc, map[string]interface{}{
+		"checklist": checklist,
+		"progress":  models.ChecklistProgress(checklist),
+		"version":   task.Version + 1,
+	})
+}
+
+func findChecklistItem(checklist []models.ChecklistItem, id string) int {
+	for i, item := range checklist {
+		if item.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func AddChecklistItemHandler(w http.ResponseWriter, r *http.Request) {
+	var request checklistItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	task, project, ok := loadChecklistTask(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	// 1. New items go to the end of the list
+	item := models.ChecklistItem{ID: primitive.NewObjectID().Hex()}
+	var errs utils.ValidationErrors
+	request.apply(project, &item, &errs)
+	if len(task.Checklist) >= 100 {
+		errs.Add("checklist", utils.CodeTooLong, "checklist must be at most 100 items")
+	}
+	if len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	saveChecklist(ctx, w, r, task, append(task.Checklist, item), "Checklist item added")
+}
+
+func UpdateChecklistItemHandler(w http.ResponseWriter, r *http.Request) {
+	var request checklistItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	task, project, ok := loadChecklistTask(ctx, w, r)
+	if !ok {
+		return
+	}
+	i := findChecklistItem(task.Checklist, r.PathValue("itemId"))
+	if i < 0 {
+		utils.SendError(w, http.StatusNotFound, "Checklist item not found")
+		return
+	}
+
+	// 1. Toggle, rename, reassign or reschedule the item in place
+	var errs utils.ValidationErrors
+	request.apply(project, &task.Checklist[i], &errs)
+	if len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	saveChecklist(ctx, w, r, task, task.Checklist, "Checklist item updated")
+}
+
+func DeleteChecklistItemHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	task, _, ok := loadChecklistTask(ctx, w, r)
+	if !ok {
+		return
+	}
+	i := findChecklistItem(task.Checklist, r.PathValue("itemId"))
+	if i < 0 {
+		utils.SendError(w, http.StatusNotFound, "Checklist item not found")
+		return
+	}
+
+	checklist := append(task.Checklist[:i:i], task.Checklist[i+1:]...)
+	saveChecklist(ctx, w, r, task, checklist, "Checklist item deleted")
+}
+
+func ReorderChecklistHandler(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ItemIDs []string `json:"itemIds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	task, _, ok := loadChecklistTask(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	// 1. The new order must name every item exactly once
+	checklist := make([]models.ChecklistItem, 0, len(request.ItemIDs))
+	seen := map[string]bool{}
+	for _, id := range request.ItemIDs {
+		i := findChecklistItem(task.Checklist, id)
+		if i < 0 || seen[id] {
+			break
+		}
+		seen[id] = true
+		checklist = append(checklist, task.Checklist[i])
+	}
+	if len(checklist) != len(request.ItemIDs) || len(checklist) != len(task.Checklist) {
+		var errs utils.ValidationErrors
+		errs.Add("itemIds", utils.CodeInvalidFormat, "itemIds must list every checklist item of the task exactly once")
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	saveChecklist(ctx, w, r, task, checklist, "Checklist reordered")
+}
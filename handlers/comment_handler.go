@@ -0,0 +1,391 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"trello-lite/databases"
+	"trello-lite/mailer"
+	"trello-lite/models"
+	"trello-lite/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Comment permissions come from the task's project, not the global role:
+// viewers read, members write, authors edit their own comments and
+// maintainers can remove anyone's.
+
+func ListCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	task, _, _, ok := authorizeTask(ctx, w, r, r.PathValue("taskId"), models.ProjectRoleViewer)
+	if !ok {
+		return
+	}
+
+	// 1. Load every comment of the task, oldest first
+	collection := databases.GetCollection(databases.Client, "comments")
+	cursor, err := collection.Find(ctx, bson.M{"taskId": task.ID}, commentOrder())
+	if err != nil {
+		log.Printf("[%s] list comments: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Failed to query comments")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	comments := []models.Comment{}
+	if err := cursor.All(ctx, &comments); err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Error parsing comments")
+		return
+	}
+
+	// 2. Nest the replies under the comments they answer
+	utils.SendSuccess(w, "Comments retrieved successfully", buildCommentThreads(comments))
+}
+
+func CreateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	var request models.CommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	task, project, _, ok := authorizeTask(ctx, w, r, r.PathValue("taskId"), models.ProjectRoleMember)
+	if !ok {
+		return
+	}
+
+	// 1. A reply must answer a comment on the same task
+	collection := databases.GetCollection(databases.Client, "comments")
+	errs := utils.Validate(request)
+	if request.ParentID != "" {
+		count, err := collection.CountDocuments(ctx, bson.M{"_id": request.ParentID, "taskId": task.ID, "deleted": bson.M{"$ne": true}})
+		if err != nil {
+			utils.SendError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if count == 0 {
+			errs.Add("parentId", utils.CodeNotFound, "parentId does not refer to a comment on this task")
+		}
+	}
+	if len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	// 2. Resolve @mentions against the project's members
+	mentioned, err := resolveMentions(ctx, project, request.Body)
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	comment := models.Comment{
+		ID:        primitive.NewObjectID().Hex(),
+		TaskID:    task.ID,
+		ProjectID: project.ID,
+		ParentID:  request.ParentID,
+		AuthorID:  r.Header.Get("User-ID"),
+		Body:      request.Body,
+		Mentions:  userIDs(mentioned),
+		CreatedAt: time.Now(),
+	}
+	if _, err := collection.InsertOne(ctx, comment); err != nil {
+		log.Printf("[%s] insert comment: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// 3. Let the mentioned members know
+	notifyMentions(ctx, r, project, task, comment, mentioned, nil)
+
+	utils.SendCreated(w, "Comment added", comment)
+}
+
+func EditCommentHandler(w http.ResponseWriter, r *http.Request) {
+	var request models.CommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	task, project, _, ok := authorizeTask(ctx, w, r, r.PathValue("taskId"), models.ProjectRoleMember)
+	if !ok {
+		return
+	}
+	comment, ok := loadComment(ctx, w, r, task)
+	if !ok {
+		return
+	}
+
+	// 1. Only the author edits a comment, and a thread cannot be rearranged
+	if comment.AuthorID != r.Header.Get("User-ID") {
+		utils.SendError(w, http.StatusForbidden, "Only the author can edit a comment")
+		return
+	}
+	errs := utils.Validate(request)
+	if request.ParentID != "" && request.ParentID != comment.ParentID {
+		errs.Add("parentId", utils.CodeNotAllowed, "A comment cannot be moved to another thread")
+	}
+	if len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+	if request.Body == comment.Body {
+		utils.SendSuccess(w, "Comment unchanged", comment)
+		return
+	}
+
+	mentioned, err := resolveMentions(ctx, project, request.Body)
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// 2. Keep the old body in the edit history. Matching on the old body
+	// makes concurrent edits fail instead of losing one of them.
+	now := time.Now()
+	edit := models.CommentEdit{Body: comment.Body, EditedAt: now, EditedBy: r.Header.Get("User-ID")}
+	update := bson.M{
+		"$set":  bson.M{"body": request.Body, "mentions": userIDs(mentioned), "editedAt": now},
+		"$push": bson.M{"edits": edit},
+	}
+	collection := databases.GetCollection(databases.Client, "comments")
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": comment.ID, "body": comment.Body, "deleted": bson.M{"$ne": true}}, update)
+	if err != nil {
+		log.Printf("[%s] edit comment: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if result.MatchedCount == 0 {
+		utils.SendError(w, http.StatusConflict, "Comment was changed by another request; reload and try again")
+		return
+	}
+
+	previous := comment.Mentions
+	comment.Body = request.Body
+	comment.Mentions = userIDs(mentioned)
+	comment.EditedAt = &now
+
+	// 3. Only members mentioned for the first time are notified
+	notifyMentions(ctx, r, project, task, comment, mentioned, previous)
+
+	utils.SendSuccess(w, "Comment updated", comment)
+}
+
+func DeleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	task, _, role, ok := authorizeTask(ctx, w, r, r.PathValue("taskId"), models.ProjectRoleMember)
+	if !ok {
+		return
+	}
+	comment, ok := loadComment(ctx, w, r, task)
+	if !ok {
+		return
+	}
+
+	// 1. Authors delete their own comments; maintainers moderate everyone's
+	if comment.AuthorID != r.Header.Get("User-ID") && !models.ProjectRoleAtLeast(role, models.ProjectRoleMaintainer) {
+		utils.SendError(w, http.StatusForbidden, "Only the author or a project maintainer can delete a comment")
+		return
+	}
+
+	// 2. A comment with replies leaves a placeholder so the thread stays intact
+	collection := databases.GetCollection(databases.Client, "comments")
+	replies, err := collection.CountDocuments(ctx, bson.M{"parentId": comment.ID})
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if replies > 0 {
+		_, err = collection.UpdateOne(ctx, bson.M{"_id": comment.ID}, bson.M{
+			"$set":   bson.M{"deleted": true, "body": "", "mentions": []string{}},
+			"$unset": bson.M{"edits": ""},
+		})
+	} else {
+		_, err = collection.DeleteOne(ctx, bson.M{"_id": comment.ID})
+	}
+	if err != nil {
+		log.Printf("[%s] delete comment: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Delete failed")
+		return
+	}
+
+	utils.SendSuccess(w, "Comment deleted", nil)
+}
+
+func CommentHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	task, _, _, ok := authorizeTask(ctx, w, r, r.PathValue("taskId"), models.ProjectRoleViewer)
+	if !ok {
+		return
+	}
+	comment, ok := loadComment(ctx, w, r, task)
+	if !ok {
+		return
+	}
+
+	history := comment.Edits
+	if history == nil {
+		history = []models.CommentEdit{}
+	}
+	utils.SendSuccess(w, "Comment history retrieved successfully", history)
+}
+
+// loadComment finds the {commentId} of the path on the given task. It writes
+// the error response and returns ok=false on failure.
+func loadComment(ctx context.Context, w http.ResponseWriter, r *http.Request, task models.Task) (models.Comment, bool) {
+	var comment models.Comment
+	collection := databases.GetCollection(databases.Client, "comments")
+	err := collection.FindOne(ctx, bson.M{"_id": r.PathValue("commentId"), "taskId": task.ID}).Decode(&comment)
+	if err == mongo.ErrNoDocuments || (err == nil && comment.Deleted) {
+		utils.SendError(w, http.StatusNotFound, "Comment not found")
+		return comment, false
+	}
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return comment, false
+	}
+	return comment, true
+}
+
+func commentOrder() *options.FindOptions {
+	return options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}})
+}
+
+// buildCommentThreads nests comments (oldest first) under their parents.
+// A reply whose parent is gone is shown at the top level.
+func buildCommentThreads(comments []models.Comment) []models.CommentThread {
+	children := map[string][]models.Comment{}
+	exists := map[string]bool{}
+	for _, c := range comments {
+		exists[c.ID] = true
+	}
+	var roots []models.Comment
+	for _, c := range comments {
+		if c.ParentID != "" && exists[c.ParentID] {
+			children[c.ParentID] = append(children[c.ParentID], c)
+		} else {
+			roots = append(roots, c)
+		}
+	}
+
+	var build func(list []models.Comment) []models.CommentThread
+	build = func(list []models.Comment) []models.CommentThread {
+		threads := make([]models.CommentThread, 0, len(list))
+		for _, c := range list {
+			threads = append(threads, models.CommentThread{Comment: c, Replies: build(children[c.ID])})
+		}
+		return threads
+	}
+	return build(roots)
+}
+
+// resolveMentions matches the @handles in a body to project members. A
+// handle is a member's email address, the part of it before the @, or their
+// name without spaces, compared case-insensitively. Handles matching nobody,
+// or more than one member, are left as plain text.
+func resolveMentions(ctx context.Context, project models.Project, body string) ([]models.User, error) {
+	handles := utils.ParseMentions(body)
+	if len(handles) == 0 {
+		return nil, nil
+	}
+
+	var ids []string
+	for _, m := range allMemberships(project) {
+		ids = append(ids, m.UserID)
+	}
+	collection := databases.GetCollection(databases.Client, "users")
+	cursor, err := collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var members []models.User
+	if err := cursor.All(ctx, &members); err != nil {
+		return nil, err
+	}
+
+	var mentioned []models.User
+	for _, handle := range handles {
+		var matches []models.User
+		for _, u := range members {
+			email := strings.ToLower(u.Email)
+			local, _, _ := strings.Cut(email, "@")
+			name := strings.ToLower(strings.Join(strings.Fields(u.Name), ""))
+			if handle == email || handle == local || handle == name {
+				matches = append(matches, u)
+			}
+		}
+		if len(matches) == 1 && !containsUser(mentioned, matches[0].ID) {
+			mentioned = append(mentioned, matches[0])
+		}
+	}
+	return mentioned, nil
+}
+
+func containsUser(users []models.User, id string) bool {
+	for _, u := range users {
+		if u.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func userIDs(users []models.User) []string {
+	ids := make([]string, 0, len(users))
+	for _, u := range users {
+		ids = append(ids, u.ID)
+	}
+	return ids
+}
+
+// notifyMentions emails the members mentioned in a comment, except the author
+// and anyone in alreadyNotified. Delivery problems are logged, not returned:
+// the comment itself has been saved.
+func notifyMentions(ctx context.Context, r *http.Request, project models.Project, task models.Task, comment models.Comment, mentioned []models.User, alreadyNotified []string) {
+	for _, u := range mentioned {
+		if u.ID == comment.AuthorID || containsString(alreadyNotified, u.ID) {
+			continue
+		}
+		err := mailer.Default.Send(ctx, mailer.Message{
+			To:      u.Email,
+			Subject: "You were mentioned on \"" + task.Title + "\"",
+			Body: "You were mentioned in a comment on the task \"" + task.Title + "\" in " + project.Name + ":\n\n" +
+				comment.Body + "\n\n" +
+				appBaseURL() + "/v1/projects/" + project.ID + "/tasks/" + task.ID + "/comments",
+		})
+		if err != nil {
+			log.Printf("[%s] mention email to %s: %v", r.Header.Get(utils.RequestIDHeader), u.ID, err)
+		}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
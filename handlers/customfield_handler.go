@@ -0,0 +1,486 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"trello-lite/databases"
+	"trello-lite/models"
+	"trello-lite/query"
+	"trello-lite/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// customFieldPath is where a task stores the value of a custom field
+func customFieldPath(field models.CustomField) string {
+	return "customFields." + field.ID
+}
+
+// customFieldSchema adds the custom fields to a filter schema as cf.<key>
+func customFieldSchema(base query.Schema, fields []models.CustomField) query.Schema {
+	if len(fields) == 0 {
+		return base
+	}
+	schema := make(query.Schema, len(base)+len(fields))
+	for name, f := range base {
+		schema[name] = f
+	}
+	for _, f := range fields {
+		qf := query.Field{Path: customFieldPath(f), Sparse: true}
+		switch f.Type {
+		case models.FieldNumber:
+			qf.Kind = query.Number
+		case models.FieldText:
+			qf.Kind = query.Text
+		case models.FieldDate:
+			qf.Kind = query.Date
+		case models.FieldSelect, models.FieldMultiSelect:
+			// A multiselect matches when any of its values does
+			qf.Kind, qf.Values = query.Enum, f.Options
+		case models.FieldUser:
+			qf.Kind = query.User
+		}
+		schema["cf."+f.Key] = qf
+	}
+	return schema
+}
+
+// customFieldSorts are the sort keys of the custom fields. A multiselect has
+// no single value to sort by.
+func customFieldSorts(fields []models.CustomField) map[string]string {
+	sorts := map[string]string{}
+	for _, f := range fields {
+		if f.Type != models.FieldMultiSelect {
+			sorts["cf."+f.Key] = customFieldPath(f)
+		}
+	}
+	return sorts
+}
+
+// customFieldValue checks a JSON value sent for a field and converts it to
+// the stored form. Empty values come back as nil, which clears the field.
+func customFieldValue(project models.Project, field models.CustomField, v interface{}) (interface{}, string) {
+	switch field.Type {
+	case models.FieldNumber:
+		n, ok := v.(float64)
+		if !ok || math.IsInf(n, 0) || math.IsNaN(n) {
+			return nil, "must be a number"
+		}
+		return n, ""
+
+	case models.FieldMultiSelect:
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil, "must be an array of options"
+		}
+		chosen := []string{}
+		for _, item := range list {
+			option, ok := item.(string)
+			if !ok || !field.HasOption(option) {
+				return nil, "must only contain options of the field: " + strings.Join(field.Options, ", ")
+			}
+			if !containsString(chosen, option) {
+				chosen = append(chosen, option)
+			}
+		}
+		if len(chosen) == 0 {
+			return nil, ""
+		}
+		return chosen, ""
+	}
+
+	// The other types are sent as strings
+	s, ok := v.(string)
+	if !ok {
+		return nil, "must be a string"
+	}
+	if s == "" {
+		return nil, ""
+	}
+	switch field.Type {
+	case models.FieldText:
+		if len(s) > models.MaxFieldTextSize {
+			return nil, fmt.Sprintf("must be at most %d characters", models.MaxFieldTextSize)
+		}
+	case models.FieldDate:
+		t, ok := parseDateParam(s)
+		if !ok {
+			return nil, "must be a date (YYYY-MM-DD) or an RFC 3339 time"
+		}
+		return t, ""
+	case models.FieldSelect:
+		if !field.HasOption(s) {
+			return nil, "must be one of " + strings.Join(field.Options, ", ")
+		}
+	case models.FieldUser:
+		if project.RoleOf(s) == "" {
+			return nil, "must be a member of the project"
+		}
+	}
+	return s, ""
+}
+
+// applyCustomFields merges values sent by a client, keyed by field ID or key,
+// into a task's current values. null or an empty value removes a value.
+func applyCustomFields(project models.Project, current, sent map[string]interface{}, errs *utils.ValidationErrors) map[string]interface{} {
+	values := map[string]interface{}{}
+	for id, v := range current {
+		values[id] = v
+	}
+
+	keys := make([]string, 0, len(sent))
+	for k := range sent {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		field, ok := project.CustomField(k)
+		if !ok {
+			errs.Add("customFields."+k, utils.CodeNotFound, "Unknown custom field \""+k+"\" for this project")
+			continue
+		}
+		if sent[k] == nil {
+			delete(values, field.ID)
+			continue
+		}
+		value, msg := customFieldValue(project, field, sent[k])
+		switch {
+		case msg != "":
+			errs.Add("customFields."+k, utils.CodeInvalidFormat, field.Name+" "+msg)
+		case value == nil:
+			delete(values, field.ID)
+		default:
+			values[field.ID] = value
+		}
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
+
+// customFieldRequest is the body of the create and update endpoints. Fields
+// left out of an update keep their value; the type cannot be changed.
+type customFieldRequest struct {
+	Key     *string   `json:"key"`
+	Name    *string   `json:"name"`
+	Type    *string   `json:"type"`
+	Options *[]string `json:"options"`
+}
+
+// apply copies the request onto field, reporting invalid fields in errs
+func (req customFieldRequest) apply(project models.Project, field *models.CustomField, errs *utils.ValidationErrors) {
+	if req.Key != nil {
+		field.Key = strings.TrimSpace(*req.Key)
+	}
+	if req.Name != nil {
+		field.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Type != nil {
+		if field.Type != "" && field.Type != *req.Type {
+			errs.Add("type", utils.CodeNotAllowed, "The type of a custom field cannot be changed")
+		}
+		field.Type = *req.Type
+	}
+	if req.Options != nil {
+		field.Options = *req.Options
+	}
+
+	for _, e := range utils.Validate(field) {
+		if !errs.Has(e.Field) {
+			*errs = append(*errs, e)
+		}
+	}
+	if field.Key != "" && !errs.Has("key") && !models.IsValidFieldKey(field.Key) {
+		errs.Add("key", utils.CodeInvalidFormat, "key must be lower-case letters, digits and underscores, starting with a letter")
+	}
+	for _, other := range project.CustomFields {
+		if other.ID != field.ID && other.Key == field.Key && !errs.Has("key") {
+			errs.Add("key", utils.CodeConflict, "The project already has a custom field with key \""+field.Key+"\"")
+		}
+	}
+
+	// Only selects have options, and each must be there once
+	if !field.HasOptions() {
+		if len(field.Options) > 0 && !errs.Has("options") {
+			errs.Add("options", utils.CodeNotAllowed, "Only select and multiselect fields have options")
+		}
+		return
+	}
+	if len(field.Options) == 0 && !errs.Has("options") {
+		errs.Add("options", utils.CodeRequired, "A select field needs at least one option")
+	}
+	seen := map[string]bool{}
+	for i, o := range field.Options {
+		if o == "" || len(o) > 100 {
+			errs.Add(fmt.Sprintf("options[%d]", i), utils.CodeInvalidFormat, "Options must be 1 to 100 characters")
+		} else if seen[o] {
+			errs.Add(fmt.Sprintf("options[%d]", i), utils.CodeConflict, "Duplicate option \""+o+"\"")
+		}
+		seen[o] = true
+	}
+}
+
+// checkNewCustomFields gives the fields a new project starts with fresh IDs
+// and checks them like the create endpoint does
+func checkNewCustomFields(fields []models.CustomField, errs *utils.ValidationErrors) []models.CustomField {
+	var accepted models.Project
+	for i, f := range fields {
+		field := models.CustomField{ID: primitive.NewObjectID().Hex()}
+		request := customFieldRequest{Key: &f.Key, Name: &f.Name, Type: &f.Type, Options: &f.Options}
+		var fieldErrs utils.ValidationErrors
+		request.apply(accepted, &field, &fieldErrs)
+		for _, e := range fieldErrs {
+			// The project's own validation may have seen the same problem
+			e.Field = fmt.Sprintf("customFields[%d].%s", i, e.Field)
+			if !errs.Has(e.Field) {
+				*errs = append(*errs, e)
+			}
+		}
+		accepted.CustomFields = append(accepted.CustomFields, field)
+	}
+	return accepted.CustomFields
+}
+
+func ListCustomFieldsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	project, _, ok := authorizeProject(ctx, w, r, r.PathValue("projectId"), models.ProjectRoleViewer)
+	if !ok {
+		return
+	}
+
+	fields := project.CustomFields
+	if fields == nil {
+		fields = []models.CustomField{}
+	}
+	utils.SendSuccess(w, "Custom fields retrieved successfully", fields)
+}
+
+func CreateCustomFieldHandler(w http.ResponseWriter, r *http.Request) {
+	var request customFieldRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 1. Maintainers and owners define the project's fields
+	project, _, ok := authorizeProject(ctx, w, r, r.PathValue("projectId"), models.ProjectRoleMaintainer)
+	if !ok {
+		return
+	}
+
+	field := models.CustomField{ID: primitive.NewObjectID().Hex()}
+	var errs utils.ValidationErrors
+	request.apply(project, &field, &errs)
+	if len(project.CustomFields) >= models.MaxCustomFields {
+		errs.Add("customFields", utils.CodeTooLong, fmt.Sprintf("A project can have at most %d custom fields", models.MaxCustomFields))
+	}
+	if len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	// 2. The key and size checks are repeated in the write, so a concurrent
+	// create cannot slip a duplicate in
+	filter := bson.M{
+		"_id":              project.ID,
+		"customFields.key": bson.M{"$ne": field.Key},
+		fmt.Sprintf("customFields.%d", models.MaxCustomFields-1): bson.M{"$exists": false},
+	}
+	update := bson.M{"$push": bson.M{"customFields": field}, "$set": bson.M{"updatedAt": time.Now()}}
+	result, err := databases.GetCollection(databases.Client, "projects").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("[%s] create custom field: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if result.MatchedCount == 0 {
+		utils.SendError(w, http.StatusConflict, "The custom fields changed concurrently; reload them and try again")
+		return
+	}
+
+	utils.SendCreated(w, "Custom field created", field)
+}
+
+func UpdateCustomFieldHandler(w http.ResponseWriter, r *http.Request) {
+	var request customFieldRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	project, _, ok := authorizeProject(ctx, w, r, r.PathValue("projectId"), models.ProjectRoleMaintainer)
+	if !ok {
+		return
+	}
+	field, found := project.CustomField(r.PathValue("fieldId"))
+	if !found || field.ID != r.PathValue("fieldId") {
+		utils.SendError(w, http.StatusNotFound, "Custom field not found")
+		return
+	}
+	previous := field
+
+	// 1. Rename, re-key or change the options; tasks store values by ID
+	var errs utils.ValidationErrors
+	request.apply(project, &field, &errs)
+	if len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	var removed []string
+	for _, o := range previous.Options {
+		if !field.HasOption(o) {
+			removed = append(removed, o)
+		}
+	}
+
+	// 2. The definition changes together with the tasks that used a removed
+	// option, so no task keeps a value the field no longer allows
+	var cleared int64
+	err := databases.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		now := time.Now()
+		filter := bson.M{
+			"_id":             project.ID,
+			"customFields.id": field.ID,
+			"customFields": bson.M{"$not": bson.M{"$elemMatch": bson.M{
+				"key": field.Key,
+				"id":  bson.M{"$ne": field.ID},
+			}}},
+		}
+		update := bson.M{"$set": bson.M{"customFields.$[f]": field, "updatedAt": now}}
+		opts := options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{"f.id": field.ID}}})
+		result, err := databases.GetCollection(databases.Client, "projects").UpdateOne(sc, filter, update, opts)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return errCustomFieldChanged
+		}
+
+		cleared = 0
+		if len(removed) == 0 {
+			return nil
+		}
+		n, err := clearFieldOptions(sc, project.ID, field, removed, now)
+		cleared = n
+		return err
+	})
+	if err == errCustomFieldChanged {
+		utils.SendError(w, http.StatusConflict, "The custom fields changed concurrently; reload them and try again")
+		return
+	}
+	if err != nil {
+		log.Printf("[%s] update custom field: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	utils.SendSuccess(w, "Custom field updated", map[string]interface{}{
+		"field":        field,
+		"tasksUpdated": cleared,
+	})
+}
+
+// errCustomFieldChanged aborts a field update that lost a race with another change
+var errCustomFieldChanged = fmt.Errorf("custom fields changed concurrently")
+
+// clearFieldOptions removes options that no longer exist from every task of
+// the project. An emptied multiselect loses its value altogether.
+func clearFieldOptions(sc mongo.SessionContext, projectID string, field models.CustomField, removed []string, now time.Time) (int64, error) {
+	tasks := databases.GetCollection(databases.Client, "tasks")
+	path := customFieldPath(field)
+	filter := bson.M{"projectid": projectID, path: bson.M{"$in": removed}}
+
+	if field.Type == models.FieldSelect {
+		result, err := tasks.UpdateMany(sc, filter,
+			bumpVersion(bson.M{"$unset": bson.M{path: ""}, "$set": bson.M{"updatedat": now}}))
+		if err != nil {
+			return 0, err
+		}
+		return result.ModifiedCount, nil
+	}
+
+	result, err := tasks.UpdateMany(sc, filter,
+		bumpVersion(bson.M{"$pull": bson.M{path: bson.M{"$in": removed}}, "$set": bson.M{"updatedat": now}}))
+	if err != nil {
+		return 0, err
+	}
+	// Already counted and versioned above
+	_, err = tasks.UpdateMany(sc, bson.M{"projectid": projectID, path: bson.A{}}, bson.M{"$unset": bson.M{path: ""}})
+	return result.ModifiedCount, err
+}
+
+func DeleteCustomFieldHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	project, _, ok := authorizeProject(ctx, w, r, r.PathValue("projectId"), models.ProjectRoleMaintainer)
+	if !ok {
+		return
+	}
+	field, found := project.CustomField(r.PathValue("fieldId"))
+	if !found || field.ID != r.PathValue("fieldId") {
+		utils.SendError(w, http.StatusNotFound, "Custom field not found")
+		return
+	}
+
+	// The field and every value stored for it go in one transaction
+	var cleared int64
+	err := databases.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		now := time.Now()
+		projects := databases.GetCollection(databases.Client, "projects")
+		result, err := projects.UpdateOne(sc, bson.M{"_id": project.ID, "customFields.id": field.ID},
+			bson.M{"$pull": bson.M{"customFields": bson.M{"id": field.ID}}, "$set": bson.M{"updatedAt": now}})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return mongo.ErrNoDocuments
+		}
+
+		path := customFieldPath(field)
+		tasks := databases.GetCollection(databases.Client, "tasks")
+		updated, err := tasks.UpdateMany(sc, bson.M{"projectid": project.ID, path: bson.M{"$exists": true}},
+			bumpVersion(bson.M{"$unset": bson.M{path: ""}, "$set": bson.M{"updatedat": now}}))
+		if err != nil {
+			return err
+		}
+		cleared = updated.ModifiedCount
+		return nil
+	})
+	if err == mongo.ErrNoDocuments {
+		utils.SendError(w, http.StatusNotFound, "Custom field not found")
+		return
+	}
+	if err != nil {
+		log.Printf("[%s] delete custom field: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Delete failed")
+		return
+	}
+
+	utils.SendSuccess(w, "Custom field deleted", map[string]interface{}{
+		"id":           field.ID,
+		"tasksUpdated": cleared,
+	})
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+	"trello-lite/models"
+	"trello-lite/utils"
+)
+
+var (
+	pointsField   = models.CustomField{ID: "f1", Key: "points", Name: "Points", Type: models.FieldNumber}
+	notesField    = models.CustomField{ID: "f2", Key: "notes", Name: "Notes", Type: models.FieldText}
+	dueField      = models.CustomField{ID: "f3", Key: "due", Name: "Due", Type: models.FieldDate}
+	severityField = models.CustomField{ID: "f4", Key: "severity", Name: "Severity", Type: models.FieldSelect, Options: []string{"S1", "S2"}}
+	tagsField     = models.CustomField{ID: "f5", Key: "tags", Name: "Tags", Type: models.FieldMultiSelect, Options: []string{"ui", "api"}}
+	reviewerField = models.CustomField{ID: "f6", Key: "reviewer", Name: "Reviewer", Type: models.FieldUser}
+)
+
+var fieldsProject = models.Project{
+	OwnerID:      "u1",
+	Memberships:  []models.ProjectMember{{UserID: "u2", Role: models.ProjectRoleMember}},
+	CustomFields: []models.CustomField{pointsField, notesField, dueField, severityField, tagsField, reviewerField},
+}
+
+func TestCustomFieldValue(t *testing.T) {
+	longText := string(make([]byte, models.MaxFieldTextSize+1))
+	tests := []struct {
+		name   string
+		field  models.CustomField
+		value  interface{}
+		want   interface{}
+		failed bool
+	}{
+		{"number", pointsField, 5.0, 5.0, false},
+		{"number as a string", pointsField, "5", nil, true},
+		{"infinite number", pointsField, math.Inf(1), nil, true},
+		{"text", notesField, "hello", "hello", false},
+		{"empty text clears", notesField, "", nil, false},
+		{"text too long", notesField, longText, nil, true},
+		{"text as a number", notesField, 3.0, nil, true},
+		{"date", dueField, "2026-11-01", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), false},
+		{"date and time", dueField, "2026-11-01T09:00:00Z", time.Date(2026, 11, 1, 9, 0, 0, 0, time.UTC), false},
+		{"bad date", dueField, "tomorrow", nil, true},
+		{"select", severityField, "S2", "S2", false},
+		{"select options are case-sensitive", severityField, "s2", nil, true},
+		{"multiselect", tagsField, []interface{}{"api", "ui", "api"}, []string{"api", "ui"}, false},
+		{"empty multiselect clears", tagsField, []interface{}{}, nil, false},
+		{"multiselect unknown option", tagsField, []interface{}{"db"}, nil, true},
+		{"multiselect as a string", tagsField, "ui", nil, true},
+		{"user", reviewerField, "u2", "u2", false},
+		{"owner is a user", reviewerField, "u1", "u1", false},
+		{"user outside the project", reviewerField, "u9", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, msg := customFieldValue(fieldsProject, tt.field, tt.value)
+			if (msg != "") != tt.failed {
+				t.Fatalf("customFieldValue() message = %q, want failure %v", msg, tt.failed)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("customFieldValue() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyCustomFields(t *testing.T) {
+	current := map[string]interface{}{"f1": 3.0, "f4": "S1"}
+	tests := []struct {
+		name string
+		sent map[string]interface{}
+		want map[string]interface{}
+		errs []string
+	}{
+		{"by key", map[string]interface{}{"points": 5.0}, map[string]interface{}{"f1": 5.0, "f4": "S1"}, nil},
+		{"by ID", map[string]interface{}{"f2": "hi"}, map[string]interface{}{"f1": 3.0, "f2": "hi", "f4": "S1"}, nil},
+		{"null removes", map[string]interface{}{"points": nil}, map[string]interface{}{"f4": "S1"}, nil},
+		{"empty removes", map[string]interface{}{"severity": ""}, map[string]interface{}{"f1": 3.0}, nil},
+		{"removing everything", map[string]interface{}{"points": nil, "severity": nil}, nil, nil},
+		{"unknown field", map[string]interface{}{"nope": 1.0}, current, []string{"customFields.nope"}},
+		{"invalid values are all reported", map[string]interface{}{"severity": "S9", "points": "x"}, current,
+			[]string{"customFields.points", "customFields.severity"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs utils.ValidationErrors
+			got := applyCustomFields(fieldsProject, current, tt.sent, &errs)
+			var fields []string
+			for _, e := range errs {
+				fields = append(fields, e.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.errs) {
+				t.Errorf("errors on %v, want %v", fields, tt.errs)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("applyCustomFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if len(current) != 2 || current["f1"] != 3.0 {
+		t.Errorf("applyCustomFields changed the current values: %v", current)
+	}
+}
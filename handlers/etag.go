@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"trello-lite/models"
+	"trello-lite/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// taskETag renders a task version as a strong ETag
+func taskETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// setTaskETag exposes the task's current version to the client
+func setTaskETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", taskETag(version))
+}
+
+// checkIfMatch enforces an If-Match precondition against the task as read.
+// Requests without If-Match are allowed through. It writes 412 and returns
+// false when the client's copy is stale.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, task models.Task) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" || header == "*" {
+		return true
+	}
+
+	current := taskETag(task.Version)
+	for _, tag := range strings.Split(header, ",") {
+		// Weak tags carry the same version number, so compare them too
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == current {
+			return true
+		}
+	}
+
+	setTaskETag(w, task.Version)
+	utils.SendErrorCode(w, http.StatusPreconditionFailed, utils.ErrVersionConflict, "Task has been modified since you last read it (current version "+strconv.FormatInt(task.Version, 10)+")")
+	return false
+}
+
+// taskVersionFilter matches the task only while it is still at the version
+// that was read, so a write based on a stale read cannot overwrite a newer one.
+// Tasks written before versioning have no version field and count as 0.
+func taskVersionFilter(task models.Task) bson.M {
+	filter := taskIDFilter(task.ID)
+	if task.Version == 0 {
+		filter["version"] = bson.M{"$in": bson.A{0, nil}}
+	} else {
+		filter["version"] = task.Version
+	}
+	return filter
+}
+
+// bumpVersion adds the version increment to an update document
+func bumpVersion(update bson.M) bson.M {
+	update["$inc"] = bson.M{"version": 1}
+	return update
+}
+
+// sendVersionConflict reports a write that lost the race against another one
+func sendVersionConflict(w http.ResponseWriter) {
+	utils.SendErrorCode(w, http.StatusPreconditionFailed, utils.ErrVersionConflict, "Task was modified concurrently; reload it and try again")
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"trello-lite/databases"
+	"trello-lite/models"
+	"trello-lite/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// exportMaxTasks caps one export; larger projects export in filtered parts
+const exportMaxTasks = 10000
+
+// exportedTask is a task as exported: labels by name and custom fields by key,
+// so the file can be read without the project's definitions
+type exportedTask struct {
+	models.Task
+	Labels       []string               `json:"labels"`
+	CustomFields map[string]interface{} `json:"customFields"`
+}
+
+// exportCell formats a stored custom field value for export. Multiselects
+// are joined with "; " in CSV and stay arrays in JSON.
+func exportCell(v interface{}) (string, interface{}) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), v
+	case primitive.DateTime:
+		t := v.Time().UTC()
+		return t.Format(time.RFC3339), t
+	case time.Time:
+		return v.UTC().Format(time.RFC3339), v.UTC()
+	case primitive.A:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, _ := exportCell(item)
+			items = append(items, s)
+		}
+		return strings.Join(items, "; "), items
+	case []string:
+		return strings.Join(v, "; "), v
+	}
+	return fmt.Sprint(v), v
+}
+
+// csvSafe stops spreadsheets from reading a cell as a formula
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// exportTimestamp formats a time for CSV, leaving unset times empty
+func exportTimestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// ExportTasksHandler downloads a project's tasks as CSV or JSON:
+// GET /projects/{projectId}/tasks/export?format=csv. It takes the same
+// filters and sort as the task list, without paging.
+func ExportTasksHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	project, _, ok := authorizeProject(ctx, w, r, r.PathValue("projectId"), models.ProjectRoleViewer)
+	if !ok {
+		return
+	}
+
+	// 1. Same filters and sort keys as GET /projects/{projectId}/tasks
+	matchCriteria := bson.M{"projectid": project.ID}
+	var errs utils.ValidationErrors
+	addTaskFilters(r, matchCriteria, projectScope(project), &errs)
+	page := parsePageRequest(r, taskListSpec.withSorts(customFieldSorts(project.CustomFields)), &errs)
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		errs.Add("format", utils.CodeInvalidChoice, "format must be csv or json")
+	}
+	if len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	// 2. Read one more than the cap to tell a full export from a cut one
+	pipeline := mongo.Pipeline{bson.D{{Key: "$match", Value: matchCriteria}}}
+	pipeline = append(pipeline, page.sortStages()...)
+	pipeline = append(pipeline,
+		bson.D{{Key: "$limit", Value: exportMaxTasks + 1}},
+		bson.D{{Key: "$addFields", Value: bson.M{"id": "$_id"}}},
+	)
+	cursor, err := databases.GetCollection(databases.Client, "tasks").Aggregate(ctx, pipeline)
+	if err != nil {
+		log.Printf("[%s] export tasks: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Error fetching tasks")
+		return
+	}
+	tasks := []models.Task{}
+	if err := cursor.All(ctx, &tasks); err != nil {
+		log.Printf("[%s] decode tasks: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Data format error")
+		return
+	}
+	if len(tasks) > exportMaxTasks {
+		utils.SendError(w, http.StatusBadRequest, fmt.Sprintf("An export is limited to %d tasks; narrow it down with filters", exportMaxTasks))
+		return
+	}
+
+	// 3. Swap IDs for names the reader can make sense of
+	labelNames := map[string]string{}
+	for _, l := range project.Labels {
+		labelNames[l.ID] = l.Name
+	}
+	exported := make([]exportedTask, len(tasks))
+	for i, t := range tasks {
+		e := exportedTask{Task: t, Labels: []string{}, CustomFields: map[string]interface{}{}}
+		for _, id := range t.Labels {
+			if name, ok := labelNames[id]; ok {
+				e.Labels = append(e.Labels, name)
+			}
+		}
+		for _, f := range project.CustomFields {
+			if v, ok := t.CustomFields[f.ID]; ok {
+				_, e.CustomFields[f.Key] = exportCell(v)
+			}
+		}
+		exported[i] = e
+	}
+
+	filename := "tasks-" + project.ID + "." + format
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(exported); err != nil {
+			log.Printf("[%s] write export: %v", r.Header.Get(utils.RequestIDHeader), err)
+		}
+		return
+	}
+
+	// 4. CSV has the fixed columns, then one per custom field under its name
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	out := csv.NewWriter(w)
+	header := []string{"id", "title", "description", "status", "priority", "duedate", "assignedto",
+		"columnId", "parentId", "labels", "createdat", "updatedat"}
+	for _, f := range project.CustomFields {
+		header = append(header, csvSafe(f.Name))
+	}
+	out.Write(header)
+	for _, e := range exported {
+		row := []string{e.ID, csvSafe(e.Title), csvSafe(e.Description), csvSafe(e.Status), e.Priority,
+			exportTimestamp(e.DueDate), e.AssignedTo, e.ColumnID, e.ParentID, csvSafe(strings.Join(e.Labels, "; ")),
+			exportTimestamp(e.CreatedAt), exportTimestamp(e.UpdatedAt)}
+		for _, f := range project.CustomFields {
+			cell, _ := exportCell(e.Task.CustomFields[f.ID])
+			if f.Type != models.FieldNumber && f.Type != models.FieldDate {
+				cell = csvSafe(cell)
+			}
+			row = append(row, cell)
+		}
+		out.Write(row)
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		log.Printf("[%s] write export: %v", r.Header.Get(utils.RequestIDHeader), err)
+	}
+}
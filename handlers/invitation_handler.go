@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"trello-lite/databases"
+	"trello-lite/mailer"
+	"trello-lite/models"
+	"trello-lite/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const invitationTTL = 7 * 24 * time.Hour
+
+var (
+	errInvitationInvalid  = errors.New("invitation is invalid, expired or already used")
+	errInvitationMismatch = errors.New("invitation was sent to a different email address")
+)
+
+// appBaseURL is used to build links in outbound email
+func appBaseURL() string {
+	if base := os.Getenv("APP_BASE_URL"); base != "" {
+		return strings.TrimRight(base, "/")
+	}
+	return "http://localhost:8080"
+}
+
+// loadPendingInvitation verifies an invitation token and returns the pending invitation it names
+func loadPendingInvitation(ctx context.Context, token string) (models.Invitation, error) {
+	var invitation models.Invitation
+
+	claims, err := utils.ParseInviteToken(token)
+	if err != nil {
+		return invitation, errInvitationInvalid
+	}
+
+	collection := databases.GetCollection(databases.Client, "invitations")
+	filter := bson.M{
+		"_id":       claims.InvitationID,
+		"status":    models.InvitationPending,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+	if err := collection.FindOne(ctx, filter).Decode(&invitation); err != nil {
+		return invitation, errInvitationInvalid
+	}
+	return invitation, nil
+}
+
+// redeemInvitation accepts an invitation on behalf of user and adds them to the
+// project with the invited role. The invitation must have been sent to the user's email.
+func redeemInvitation(ctx context.Context, token string, user models.User) (models.Invitation, error) {
+	invitation, err := loadPendingInvitation(ctx, token)
+	if err != nil {
+		return invitation, err
+	}
+	if !strings.EqualFold(invitation.Email, user.Email) {
+		return invitation, errInvitationMismatch
+	}
+
+	// 1. Claim the invitation atomically so it can only be used once
+	now := time.Now()
+	collection := databases.GetCollection(databases.Client, "invitations")
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": invitation.ID, "status": models.InvitationPending},
+		bson.M{"$set": bson.M{"status": models.InvitationAccepted, "respondedAt": now, "acceptedBy": user.ID}},
+	)
+	if err != nil {
+		return invitation, err
+	}
+	if result.MatchedCount == 0 {
+		return invitation, errInvitationInvalid
+	}
+
+	// 2. Add the membership unless they already joined some other way
+	projColl := databases.GetCollection(databases.Client, "projects")
+	member := models.ProjectMember{UserID: user.ID, Role: invitation.Role, AddedAt: now}
+	_, err = projColl.UpdateOne(ctx,
+		bson.M{
+			"_id":                invitation.ProjectID,
+			"ownerId":            bson.M{"$ne": user.ID},
+			"memberships.userId": bson.M{"$ne": user.ID},
+		},
+		bson.M{
+			"$push":     bson.M{"memberships": member},
+			"$addToSet": bson.M{"memberIds": user.ID},
+		},
+	)
+	invitation.Status = models.InvitationAccepted
+	return invitation, err
+}
+
+func CreateInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ProjectID string `json:"projectId" validate:"required"`
+		Email     string `json:"email" validate:"required,email,max=254"`
+		Role      string `json:"role" validate:"oneof=viewer|member|maintainer"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	request.ProjectID = pathParam(r, "projectId", request.ProjectID)
+	request.Email = strings.TrimSpace(request.Email)
+	if request.Role == "" {
+		request.Role = models.ProjectRoleMember
+	}
+	if errs := utils.Validate(request); len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// 1. Same rules as adding a member directly
+	project, callerRole, ok := authorizeProject(ctx, w, r, request.ProjectID, models.ProjectRoleMaintainer)
+	if !ok {
+		return
+	}
+	if !canManageMember(callerRole, request.Role) {
+		utils.SendError(w, http.StatusForbidden, "You cannot grant the "+request.Role+" role")
+		return
+	}
+
+	// 2. Store the invitation and sign a token that points at it
+	now := time.Now()
+	invitation := models.Invitation{
+		ID:        primitive.NewObjectID().Hex(),
+		ProjectID: project.ID,
+		Email:     request.Email,
+		Role:      request.Role,
+		InvitedBy: r.Header.Get("User-ID"),
+		Status:    models.InvitationPending,
+		CreatedAt: now,
+		ExpiresAt: now.Add(invitationTTL),
+	}
+
+	token, err := utils.GenerateInviteToken(invitation.ID, invitation.Email, invitation.ExpiresAt)
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Error generating invitation")
+		return
+	}
+
+	collection := databases.GetCollection(databases.Client, "invitations")
+	if _, err := collection.InsertOne(ctx, invitation); err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// 3. Email the link. The token is only ever sent to the invitee. The link
+	// opens the web client, which logs the invitee in or signs them up and
+	// then redeems the token with POST /v1/invitations/accept.
+	link := appBaseURL() + "/invite?token=" + url.QueryEscape(token)
+	err = mailer.Default.Send(ctx, mailer.Message{
+		To:      invitation.Email,
+		Subject: "You have been invited to " + project.Name,
+		Body: "You have been invited to join the project \"" + project.Name + "\" as " + invitation.Role + ".\n\n" +
+			"Accept the invitation: " + link + "\n" +
+			"If you do not have an account yet, sign up with this email and include the token.\n\n" +
+			"Token: " + token + "\n\nThis invitation expires on " + invitation.ExpiresAt.Format(time.RFC1123) + ".",
+	})
+	if err != nil {
+		utils.SendError(w, http.StatusBadGateway, "Invitation saved but the email could not be sent")
+		return
+	}
+
+	utils.SendSuccess(w, "Invitation sent", invitation)
+}
+
+func ListInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	project, _, ok := authorizeProject(ctx, w, r, pathParam(r, "projectId", r.URL.Query().Get("projectId")), models.ProjectRoleMaintainer)
+	if !ok {
+		return
+	}
+
+	filter := bson.M{"projectId": project.ID}
+	if status := r.URL.Query().Get("status"); status != "" {
+		filter["status"] = status
+	}
+
+	collection := databases.GetCollection(databases.Client, "invitations")
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Failed to query invitations")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	invitations := []models.Invitation{}
+	if err := cursor.All(ctx, &invitations); err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Error parsing invitations")
+		return
+	}
+
+	utils.SendSuccess(w, "Invitations retrieved successfully", invitations)
+}
+
+func RevokeInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ID string `json:"id"`
+	}
+	if err := decodeOptionalBody(r, &request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	request.ID = pathParam(r, "invitationId", request.ID)
+	if request.ID == "" {
+		utils.SendError(w, http.StatusBadRequest, "id is required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := databases.GetCollection(databases.Client, "invitations")
+	var invitation models.Invitation
+	if err := collection.FindOne(ctx, bson.M{"_id": request.ID}).Decode(&invitation); err != nil {
+		utils.SendError(w, http.StatusNotFound, "Invitation not found")
+		return
+	}
+
+	if _, _, ok := authorizeProject(ctx, w, r, invitation.ProjectID, models.ProjectRoleMaintainer); !ok {
+		return
+	}
+
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": invitation.ID, "status": models.InvitationPending},
+		bson.M{"$set": bson.M{"status": models.InvitationRevoked, "respondedAt": time.Now()}},
+	)
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if result.MatchedCount == 0 {
+		utils.SendError(w, http.StatusConflict, "Only pending invitations can be revoked")
+		return
+	}
+
+	utils.SendSuccess(w, "Invitation revoked", nil)
+}
+
+func AcceptInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Token == "" {
+		utils.SendError(w, http.StatusBadRequest, "token is required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var user models.User
+	userColl := databases.GetCollection(databases.Client, "users")
+	if err := userColl.FindOne(ctx, bson.M{"_id": r.Header.Get("User-ID")}).Decode(&user); err != nil {
+		utils.SendError(w, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	invitation, err := redeemInvitation(ctx, request.Token, user)
+	if err == errInvitationMismatch {
+		utils.SendError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		utils.SendError(w, http.StatusBadRequest, errInvitationInvalid.Error())
+		return
+	}
+
+	utils.SendSuccess(w, "Invitation accepted", invitation)
+}
+
+// DeclineInvitationHandler needs no login: holding the emailed token is enough to say no
+func DeclineInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Token == "" {
+		utils.SendError(w, http.StatusBadRequest, "token is required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	invitation, err := loadPendingInvitation(ctx, request.Token)
+	if err != nil {
+		utils.SendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	collection := databases.GetCollection(databases.Client, "invitations")
+	_, err = collection.UpdateOne(ctx,
+		bson.M{"_id": invitation.ID, "status": models.InvitationPending},
+		bson.M{"$set": bson.M{"status": models.InvitationDeclined, "respondedAt": time.Now()}},
+	)
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	utils.SendSuccess(w, "Invitation declined", nil)
+}
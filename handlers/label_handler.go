@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"trello-lite/databases"
+	"trello-lite/models"
+	"trello-lite/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// labelRequest is the body of the create and update endpoints. Fields left
+// out of an update keep their value.
+type labelRequest struct {
+	Name  *string `json:"name"`
+	Color *string `json:"color"`
+}
+
+// apply copies the request onto label, reporting invalid fields in errs
+func (req labelRequest) apply(project models.Project, label *models.Label, errs *utils.ValidationErrors) {
+	if req.Name != nil {
+		label.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Color != nil {
+		label.Color = *req.Color
+		if color, ok := models.NormalizeLabelColor(*req.Color); ok {
+			label.Color = color
+		} else if *req.Color != "" {
+			errs.Add("color", utils.CodeInvalidFormat, "color must be a hex color such as #d73a4a")
+		}
+	}
+	for _, e := range utils.Validate(label) {
+		if !errs.Has(e.Field) {
+			*errs = append(*errs, e)
+		}
+	}
+	if other, ok := project.LabelNamed(label.Name); ok && other.ID != label.ID && !errs.Has("name") {
+		errs.Add("name", utils.CodeConflict, "The project already has a label named \""+other.Name+"\"")
+	}
+}
+
+// labelNameFilter matches a label name exactly, ignoring case
+func labelNameFilter(name string) primitive.Regex {
+	return primitive.Regex{Pattern: "^" + regexp.QuoteMeta(name) + "$", Options: "i"}
+}
+
+// checkTaskLabels checks that every label ID of a task is in the project's
+// catalog and returns them without duplicates
+func checkTaskLabels(project models.Project, labels []string, errs *utils.ValidationErrors) []string {
+	unique := []string{}
+	for i, id := range labels {
+		if _, ok := project.Label(id); !ok {
+			errs.Add(fmt.Sprintf("labels[%d]", i), utils.CodeNotFound, "Unknown label \""+id+"\" for this project")
+			continue
+		}
+		if !containsString(unique, id) {
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// projectLabels collects the label catalogs of the given projects, or of
+// every project when all is set
+func projectLabels(ctx context.Context, projectIDs []string, all bool) ([]models.Label, error) {
+	filter := bson.M{"labels.0": bson.M{"$exists": true}}
+	if !all {
+		filter["_id"] = bson.M{"$in": projectIDs}
+	}
+	opts := options.Find().SetProjection(bson.M{"labels": 1})
+	cursor, err := databases.GetCollection(databases.Client, "projects").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var projects []models.Project
+	if err := cursor.All(ctx, &projects); err != nil {
+		return nil, err
+	}
+
+	labels := []models.Label{}
+	for _, p := range projects {
+		labels = append(labels, p.Labels...)
+	}
+	return labels, nil
+}
+
+func ListLabelsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	project, _, ok := authorizeProject(ctx, w, r, r.PathValue("projectId"), models.ProjectRoleViewer)
+	if !ok {
+		return
+	}
+
+	labels := project.Labels
+	if labels == nil {
+		labels = []models.Label{}
+	}
+	utils.SendSuccess(w, "Labels retrieved successfully", labels)
+}
+
+func CreateLabelHandler(w http.ResponseWriter, r *http.Request) {
+	var request labelRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 1. Maintainers and owners manage the catalog
+	project, _, ok := authorizeProject(ctx, w, r, r.PathValue("projectId"), models.ProjectRoleMaintainer)
+	if !ok {
+		return
+	}
+
+	label := models.Label{ID: primitive.NewObjectID().Hex()}
+	var errs utils.ValidationErrors
+	request.apply(project, &label, &errs)
+	if len(project.Labels) >= models.MaxLabels {
+		errs.Add("labels", utils.CodeTooLong, fmt.Sprintf("A project can have at most %d labels", models.MaxLabels))
+	}
+	if len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	// 2. The name and size checks are repeated in the write, so a concurrent
+	// create cannot slip a duplicate in
+	filter := bson.M{
+		"_id":         project.ID,
+		"labels.name": bson.M{"$not": labelNameFilter(label.Name)},
+		fmt.Sprintf("labels.%d", models.MaxLabels-1): bson.M{"$exists": false},
+	}
+	update := bson.M{"$push": bson.M{"labels": label}, "$set": bson.M{"updatedAt": time.Now()}}
+	result, err := databases.GetCollection(databases.Client, "projects").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("[%s] create label: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if result.MatchedCount == 0 {
+		utils.SendError(w, http.StatusConflict, "The labels changed concurrently; reload them and try again")
+		return
+	}
+
+	utils.SendCreated(w, "Label created", label)
+}
+
+func UpdateLabelHandler(w http.ResponseWriter, r *http.Request) {
+	var request labelRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	project, _, ok := authorizeProject(ctx, w, r, r.PathValue("projectId"), models.ProjectRoleMaintainer)
+	if !ok {
+		return
+	}
+	label, found := project.Label(r.PathValue("labelId"))
+	if !found {
+		utils.SendError(w, http.StatusNotFound, "Label not found")
+		return
+	}
+
+	// 1. Rename or recolour; tasks refer to the ID and follow along
+	var errs utils.ValidationErrors
+	request.apply(project, &label, &errs)
+	if len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	filter := bson.M{
+		"_id":       project.ID,
+		"labels.id": label.ID,
+		"labels": bson.M{"$not": bson.M{"$elemMatch": bson.M{
+			"name": labelNameFilter(label.Name),
+			"id":   bson.M{"$ne": label.ID},
+		}}},
+	}
+	update := bson.M{"$set": bson.M{"labels.$[l]": label, "updatedAt": time.Now()}}
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{"l.id": label.ID}}})
+	result, err := databases.GetCollection(databases.Client, "projects").UpdateOne(ctx, filter, update, opts)
+	if err != nil {
+		log.Printf("[%s] update label: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if result.MatchedCount == 0 {
+		utils.SendError(w, http.StatusConflict, "The labels changed concurrently; reload them and try again")
+		return
+	}
+
+	utils.SendSuccess(w, "Label updated", label)
+}
+
+func DeleteLabelHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	project, _, ok := authorizeProject(ctx, w, r, r.PathValue("projectId"), models.ProjectRoleMaintainer)
+	if !ok {
+		return
+	}
+	labelID := r.PathValue("labelId")
+
+	// The label leaves the catalog and every task in one transaction, so no
+	// task is left pointing at a label that no longer exists. Tasks that lose
+	// the label get a new version like any other task write.
+	var untagged int64
+	err := databases.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		now := time.Now()
+		projects := databases.GetCollection(databases.Client, "projects")
+		result, err := projects.UpdateOne(sc, bson.M{"_id": project.ID, "labels.id": labelID},
+			bson.M{"$pull": bson.M{"labels": bson.M{"id": labelID}}, "$set": bson.M{"updatedAt": now}})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return mongo.ErrNoDocuments
+		}
+
+		tasks := databases.GetCollection(databases.Client, "tasks")
+		updated, err := tasks.UpdateMany(sc, bson.M{"projectid": project.ID, "labels": labelID},
+			bumpVersion(bson.M{"$pull": bson.M{"labels": labelID}, "$set": bson.M{"updatedat": now}}))
+		if err != nil {
+			return err
+		}
+		untagged = updated.ModifiedCount
+		return nil
+	})
+	if err == mongo.ErrNoDocuments {
+		utils.SendError(w, http.StatusNotFound, "Label not found")
+		return
+	}
+	if err != nil {
+		log.Printf("[%s] delete label: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Delete failed")
+		return
+	}
+
+	utils.SendSuccess(w, "Label deleted", map[string]interface{}{
+		"id":           labelID,
+		"tasksUpdated": untagged,
+	})
+}
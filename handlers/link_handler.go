@@ -0,0 +1,545 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"trello-lite/databases"
+	"trello-lite/models"
+	"trello-lite/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// graphMaxDepth bounds how many blocks links the dependency graph follows
+// away from its task in each direction
+const graphMaxDepth = 20
+
+// errDependencyCycle aborts the transaction creating a blocks link that would close a loop
+var errDependencyCycle = errors.New("dependency cycle")
+
+// blockingLinks walks the blocks links from root: downstream to the tasks it
+// blocks, the tasks those block and so on, or upstream to its blockers. A
+// negative maxDepth walks the whole graph; otherwise truncated reports that
+// the walk stopped at links that may lead further.
+func blockingLinks(ctx context.Context, root string, downstream bool, maxDepth int) ([]models.TaskLink, bool, error) {
+	lookup := bson.M{
+		"from":                    "task_links",
+		"startWith":               bson.M{"$literal": root},
+		"connectFromField":        "fromTaskId",
+		"connectToField":          "toTaskId",
+		"restrictSearchWithMatch": bson.M{"type": models.LinkBlocks},
+		"depthField":              "depth",
+		"as":                      "links",
+	}
+	if downstream {
+		lookup["connectFromField"], lookup["connectToField"] = "toTaskId", "fromTaskId"
+	}
+	if maxDepth >= 0 {
+		lookup["maxDepth"] = maxDepth
+	}
+
+	collection := databases.GetCollection(databases.Client, "tasks")
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: taskIDFilter(root)}},
+		{{Key: "$graphLookup", Value: lookup}},
+		{{Key: "$project", Value: bson.M{"links": 1}}},
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	defer cursor.Close(ctx)
+
+	var result []struct {
+		Links []struct {
+			models.TaskLink `bson:",inline"`
+			Depth           int `bson:"depth"`
+		} `bson:"links"`
+	}
+	if err := cursor.All(ctx, &result); err != nil || len(result) == 0 {
+		return nil, false, err
+	}
+
+	links := make([]models.TaskLink, len(result[0].Links))
+	truncated := false
+	for i, l := range result[0].Links {
+		links[i] = l.TaskLink
+		truncated = truncated || l.Depth == maxDepth
+	}
+	return links, truncated, nil
+}
+
+// blocksPath returns the chain of tasks from one task to another along
+// blocks links, both ends included, or nil when there is none
+func blocksPath(links []models.TaskLink, from, to string) []string {
+	next := map[string][]string{}
+	for _, l := range links {
+		next[l.FromTaskID] = append(next[l.FromTaskID], l.ToTaskID)
+	}
+
+	prev := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == to {
+			path := []string{}
+			for ; id != ""; id = prev[id] {
+				path = append([]string{id}, path...)
+			}
+			return path
+		}
+		for _, n := range next[id] {
+			if _, seen := prev[n]; !seen {
+				prev[n] = id
+				queue = append(queue, n)
+			}
+		}
+	}
+	return nil
+}
+
+// loadTaskRefs loads the tasks with the given IDs in their short form, with
+// Done worked out from each task's own project workflow. Tasks that no longer
+// exist are left out. The projects are returned for visibleRef.
+func loadTaskRefs(ctx context.Context, ids []string) (map[string]models.TaskRef, map[string]models.Project, error) {
+	refs := map[string]models.TaskRef{}
+	projects := map[string]models.Project{}
+	if len(ids) == 0 {
+		return refs, projects, nil
+	}
+
+	// 1. The tasks, and the projects they belong to
+	opts := options.Find().SetProjection(bson.M{"title": 1, "status": 1, "projectid": 1, "duedate": 1})
+	cursor, err := databases.GetCollection(databases.Client, "tasks").Find(ctx, taskIDsFilter(ids), opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	var tasks []models.Task
+	if err := cursor.All(ctx, &tasks); err != nil {
+		return nil, nil, err
+	}
+
+	projectIDs := []string{}
+	for _, t := range tasks {
+		if !containsString(projectIDs, t.ProjectId) {
+			projectIDs = append(projectIDs, t.ProjectId)
+		}
+	}
+	cursor, err = databases.GetCollection(databases.Client, "projects").Find(ctx, bson.M{"_id": bson.M{"$in": projectIDs}})
+	if err != nil {
+		return nil, nil, err
+	}
+	var found []models.Project
+	if err := cursor.All(ctx, &found); err != nil {
+		return nil, nil, err
+	}
+	for _, p := range found {
+		projects[p.ID] = p
+	}
+
+	// 2. Done depends on the workflow of the task's own project
+	for _, t := range tasks {
+		ref := models.TaskRef{
+			ID:        t.ID,
+			Title:     t.Title,
+			Status:    t.Status,
+			ProjectID: t.ProjectId,
+			Done:      projects[t.ProjectId].EffectiveWorkflow().IsDone(t.Status),
+		}
+		if !t.DueDate.IsZero() {
+			due := t.DueDate
+			ref.DueDate = &due
+		}
+		refs[t.ID] = ref
+	}
+	return refs, projects, nil
+}
+
+// visibleRef hides the details of a task in a project the caller does not
+// belong to; only its ID and whether it is done remain
+func visibleRef(r *http.Request, projects map[string]models.Project, ref models.TaskRef) models.TaskRef {
+	if callerProjectRole(r, projects[ref.ProjectID]) == "" {
+		return models.TaskRef{ID: ref.ID, Done: ref.Done, Hidden: true}
+	}
+	return ref
+}
+
+// loadDependencies collects the direct links of a task, oldest first
+func loadDependencies(ctx context.Context, r *http.Request, task models.Task) (*models.TaskDependencies, error) {
+	collection := databases.GetCollection(databases.Client, "task_links")
+	filter := bson.M{"$or": []bson.M{{"fromTaskId": task.ID}, {"toTaskId": task.ID}}}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var links []models.TaskLink
+	if err := cursor.All(ctx, &links); err != nil {
+		return nil, err
+	}
+
+	other := func(l models.TaskLink) string {
+		if l.FromTaskID == task.ID {
+			return l.ToTaskID
+		}
+		return l.FromTaskID
+	}
+	ids := make([]string, len(links))
+	for i, l := range links {
+		ids[i] = other(l)
+	}
+	refs, projects, err := loadTaskRefs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	deps := &models.TaskDependencies{Blocks: []models.LinkedTask{}, BlockedBy: []models.LinkedTask{}, RelatesTo: []models.LinkedTask{}}
+	for _, l := range links {
+		ref, ok := refs[other(l)]
+		if !ok {
+			continue
+		}
+		linked := models.LinkedTask{LinkID: l.ID, TaskRef: visibleRef(r, projects, ref)}
+		switch {
+		case l.Type == models.LinkRelates:
+			deps.RelatesTo = append(deps.RelatesTo, linked)
+		case l.FromTaskID == task.ID:
+			deps.Blocks = append(deps.Blocks, linked)
+		default:
+			deps.BlockedBy = append(deps.BlockedBy, linked)
+			deps.Blocked = deps.Blocked || !ref.Done
+		}
+	}
+	return deps, nil
+}
+
+// checkBlockers applies the workflow's OpenBlockers to an open task moving
+// to done status to. A refused move returns its HTTP status and message like
+// checkTransition; an allowed one may still come with warnings.
+func checkBlockers(ctx context.Context, project models.Project, task models.Task, to string) ([]string, int, string) {
+	workflow := project.EffectiveWorkflow()
+	if !workflow.IsDone(to) || workflow.IsDone(task.Status) {
+		return nil, 0, ""
+	}
+
+	collection := databases.GetCollection(databases.Client, "task_links")
+	values, err := collection.Distinct(ctx, "fromTaskId", bson.M{"type": models.LinkBlocks, "toTaskId": task.ID})
+	if err != nil {
+		return nil, http.StatusInternalServerError, "Database error"
+	}
+	blockers := make([]string, 0, len(values))
+	for _, v := range values {
+		if id, ok := v.(string); ok {
+			blockers = append(blockers, id)
+		}
+	}
+
+	// Each blocker counts as done by its own project's workflow
+	refs, _, err := loadTaskRefs(ctx, blockers)
+	if err != nil {
+		return nil, http.StatusInternalServerError, "Database error"
+	}
+	open := 0
+	for _, ref := range refs {
+		if !ref.Done {
+			open++
+		}
+	}
+	if open == 0 {
+		return nil, 0, ""
+	}
+
+	msg := fmt.Sprintf("Task is blocked by %d open task(s)", open)
+	if workflow.OpenBlockers == models.BlockersReject {
+		return nil, http.StatusConflict, msg
+	}
+	return []string{msg}, 0, ""
+}
+
+// sendBlockersError reports a move refused by checkBlockers
+func sendBlockersError(w http.ResponseWriter, code int, msg string) {
+	if code == http.StatusConflict {
+		utils.SendErrorCode(w, code, utils.ErrTaskBlocked, msg)
+		return
+	}
+	utils.SendError(w, code, msg)
+}
+
+// longestChain returns the longest chain of open tasks that starts at id and
+// follows next
+func longestChain(id string, next map[string][]string, refs map[string]models.TaskRef, memo map[string][]string) []string {
+	if chain, ok := memo[id]; ok {
+		return chain
+	}
+	// Cycles are refused when links are created; this still stops a walk
+	// that meets one
+	memo[id] = []string{id}
+
+	best := []string{}
+	for _, n := range next[id] {
+		if ref, ok := refs[n]; !ok || ref.Done {
+			continue
+		}
+		if chain := longestChain(n, next, refs, memo); len(chain) > len(best) {
+			best = chain
+		}
+	}
+	memo[id] = append([]string{id}, best...)
+	return memo[id]
+}
+
+// criticalPath is the longest chain of open tasks running through root:
+// its longest line of open blockers followed by the longest line of open
+// tasks it holds up
+func criticalPath(root string, upstream, downstream []models.TaskLink, refs map[string]models.TaskRef) []string {
+	blockedBy := map[string][]string{}
+	for _, l := range upstream {
+		blockedBy[l.ToTaskID] = append(blockedBy[l.ToTaskID], l.FromTaskID)
+	}
+	blocks := map[string][]string{}
+	for _, l := range downstream {
+		blocks[l.FromTaskID] = append(blocks[l.FromTaskID], l.ToTaskID)
+	}
+
+	before := longestChain(root, blockedBy, refs, map[string][]string{})
+	after := longestChain(root, blocks, refs, map[string][]string{})
+
+	path := make([]string, 0, len(before)+len(after)-1)
+	for i := len(before) - 1; i >= 0; i-- {
+		path = append(path, before[i])
+	}
+	return append(path, after[1:]...)
+}
+
+func CreateLinkHandler(w http.ResponseWriter, r *http.Request) {
+	var request models.LinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.Validate(request); len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 1. Members link the task in the path; the other task only has to be
+	// one the caller can see, in any project
+	task, _, _, ok := authorizeTask(ctx, w, r, r.PathValue("taskId"), models.ProjectRoleMember)
+	if !ok {
+		return
+	}
+
+	var errs utils.ValidationErrors
+	var other models.Task
+	err := databases.GetCollection(databases.Client, "tasks").FindOne(ctx, taskIDFilter(request.TaskID)).Decode(&other)
+	if err != nil && err != mongo.ErrNoDocuments {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if err == nil {
+		_, _, code, _ := loadProjectForCaller(ctx, r, other.ProjectId, models.ProjectRoleViewer)
+		if code == http.StatusInternalServerError {
+			utils.SendError(w, code, "Database error")
+			return
+		}
+		if code != 0 {
+			err = mongo.ErrNoDocuments
+		}
+	}
+	if err != nil {
+		errs.Add("taskId", utils.CodeNotFound, "taskId is not a task you can see")
+	} else if other.ID == task.ID {
+		errs.Add("taskId", utils.CodeNotAllowed, "A task cannot be linked to itself")
+	}
+	if len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	// 2. Blocks links are stored in their direction, relates links once per pair
+	link := models.TaskLink{
+		ID:            primitive.NewObjectID().Hex(),
+		Type:          models.LinkBlocks,
+		FromTaskID:    task.ID,
+		FromProjectID: task.ProjectId,
+		ToTaskID:      other.ID,
+		ToProjectID:   other.ProjectId,
+		CreatedBy:     r.Header.Get("User-ID"),
+		CreatedAt:     time.Now(),
+	}
+	if request.Type == models.LinkRelates {
+		link.Type = models.LinkRelates
+	}
+	if request.Type == "blocked_by" || (link.Type == models.LinkRelates && other.ID < task.ID) {
+		link.FromTaskID, link.ToTaskID = link.ToTaskID, link.FromTaskID
+		link.FromProjectID, link.ToProjectID = link.ToProjectID, link.FromProjectID
+	}
+
+	// 3. A blocks link must not close a loop: refuse it when its target
+	// already blocks its source, directly or through other tasks. Every
+	// blocks link is created behind the same lock document, so two links
+	// that would only form a loop together cannot both pass the check.
+	var cycle []string
+	links := databases.GetCollection(databases.Client, "task_links")
+	err = databases.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		cycle = nil
+		if link.Type == models.LinkBlocks {
+			locks := databases.GetCollection(databases.Client, "link_locks")
+			if _, err := locks.UpdateOne(sc, bson.M{"_id": models.LinkBlocks},
+				bson.M{"$inc": bson.M{"n": 1}}, options.Update().SetUpsert(true)); err != nil {
+				return err
+			}
+
+			downstream, _, err := blockingLinks(sc, link.ToTaskID, true, -1)
+			if err != nil {
+				return err
+			}
+			if cycle = blocksPath(downstream, link.ToTaskID, link.FromTaskID); cycle != nil {
+				return errDependencyCycle
+			}
+		}
+		_, err := links.InsertOne(sc, link)
+		return err
+	})
+	if errors.Is(err, errDependencyCycle) {
+		path := append([]string{link.FromTaskID}, cycle...)
+		utils.SendErrorCode(w, http.StatusConflict, utils.ErrDependencyCycle, "Link would create a dependency cycle: "+strings.Join(path, " → "))
+		return
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		utils.SendError(w, http.StatusConflict, "These tasks are already linked")
+		return
+	}
+	if err != nil {
+		log.Printf("[%s] create link: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	utils.SendCreated(w, "Link created", link)
+}
+
+func ListLinksHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	task, _, _, ok := authorizeTask(ctx, w, r, r.PathValue("taskId"), models.ProjectRoleViewer)
+	if !ok {
+		return
+	}
+
+	deps, err := loadDependencies(ctx, r, task)
+	if err != nil {
+		log.Printf("[%s] list links: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	utils.SendSuccess(w, "Links retrieved successfully", deps)
+}
+
+func DeleteLinkHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Members of either linked task's project may remove the link
+	task, _, _, ok := authorizeTask(ctx, w, r, r.PathValue("taskId"), models.ProjectRoleMember)
+	if !ok {
+		return
+	}
+
+	filter := bson.M{
+		"_id": r.PathValue("linkId"),
+		"$or": []bson.M{{"fromTaskId": task.ID}, {"toTaskId": task.ID}},
+	}
+	result, err := databases.GetCollection(databases.Client, "task_links").DeleteOne(ctx, filter)
+	if err != nil {
+		log.Printf("[%s] delete link: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Delete failed")
+		return
+	}
+	if result.DeletedCount == 0 {
+		utils.SendError(w, http.StatusNotFound, "Link not found")
+		return
+	}
+
+	utils.SendSuccess(w, "Link deleted", nil)
+}
+
+func GetDependencyGraphHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	task, _, _, ok := authorizeTask(ctx, w, r, r.PathValue("taskId"), models.ProjectRoleViewer)
+	if !ok {
+		return
+	}
+
+	// 1. Follow the blocks links both ways from the task
+	upstream, truncatedUp, err := blockingLinks(ctx, task.ID, false, graphMaxDepth)
+	if err != nil {
+		log.Printf("[%s] dependency graph: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	downstream, truncatedDown, err := blockingLinks(ctx, task.ID, true, graphMaxDepth)
+	if err != nil {
+		log.Printf("[%s] dependency graph: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// 2. Every task met on the way becomes a node, hidden if the caller
+	// cannot see its project
+	all := append(append([]models.TaskLink{}, upstream...), downstream...)
+	ids := []string{task.ID}
+	for _, l := range all {
+		for _, id := range []string{l.FromTaskID, l.ToTaskID} {
+			if !containsString(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	refs, projects, err := loadTaskRefs(ctx, ids)
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	graph := models.DependencyGraph{
+		Root:      task.ID,
+		Nodes:     []models.TaskRef{},
+		Edges:     []models.GraphEdge{},
+		Truncated: truncatedUp || truncatedDown,
+	}
+	for _, id := range ids {
+		if ref, ok := refs[id]; ok {
+			graph.Nodes = append(graph.Nodes, visibleRef(r, projects, ref))
+		}
+	}
+	for _, l := range all {
+		_, fromOK := refs[l.FromTaskID]
+		_, toOK := refs[l.ToTaskID]
+		if fromOK && toOK {
+			graph.Edges = append(graph.Edges, models.GraphEdge{LinkID: l.ID, From: l.FromTaskID, To: l.ToTaskID})
+		}
+	}
+
+	// 3. Done tasks no longer hold anything up, so the critical path only
+	// runs through open ones
+	graph.CriticalPath = criticalPath(task.ID, upstream, downstream, refs)
+
+	utils.SendSuccess(w, "Dependency graph retrieved successfully", graph)
+}
@@ -0,0 +1,429 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+	"trello-lite/databases"
+	"trello-lite/models"
+	"trello-lite/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// memberView is one row of the member listing: the membership joined with the user
+type memberView struct {
+	UserID  string    `json:"userId"`
+	Name    string    `json:"name"`
+	Email   string    `json:"email"`
+	Role    string    `json:"role"`
+	AddedAt time.Time `json:"addedAt"`
+}
+
+// allMemberships returns every membership of the project, including the owner
+// and legacy MemberIDs entries that have no membership record yet
+func allMemberships(project models.Project) []models.ProjectMember {
+	seen := map[string]bool{}
+	members := []models.ProjectMember{}
+
+	if project.OwnerID != "" {
+		members = append(members, models.ProjectMember{UserID: project.OwnerID, Role: models.ProjectRoleOwner, AddedAt: project.CreatedAt})
+		seen[project.OwnerID] = true
+	}
+	for _, m := range project.Memberships {
+		if !seen[m.UserID] {
+			members = append(members, m)
+			seen[m.UserID] = true
+		}
+	}
+	for _, id := range project.MemberIDs {
+		if !seen[id] {
+			members = append(members, models.ProjectMember{UserID: id, Role: models.ProjectRoleMember, AddedAt: project.CreatedAt})
+			seen[id] = true
+		}
+	}
+	return members
+}
+
+// canManageMember reports whether a caller with callerRole may change the
+// membership of someone who has (or would get) targetRole. Nobody can grant
+// more than they hold, and only owners can manage maintainers.
+func canManageMember(callerRole, targetRole string) bool {
+	if callerRole == models.ProjectRoleOwner {
+		return true
+	}
+	return models.ProjectRoleAtLeast(callerRole, models.ProjectRoleMaintainer) &&
+		!models.ProjectRoleAtLeast(targetRole, models.ProjectRoleMaintainer)
+}
+
+// userExists checks that a user ID refers to a real account
+func userExists(ctx context.Context, userID string) (bool, error) {
+	collection := databases.GetCollection(databases.Client, "users")
+	count, err := collection.CountDocuments(ctx, bson.M{"_id": userID})
+	return count > 0, err
+}
+
+func ListProjectMembersHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	project, _, ok := authorizeProject(ctx, w, r, pathParam(r, "projectId", r.URL.Query().Get("projectId")), models.ProjectRoleViewer)
+	if !ok {
+		return
+	}
+
+	memberships := allMemberships(project)
+	ids := make([]string, 0, len(memberships))
+	for _, m := range memberships {
+		ids = append(ids, m.UserID)
+	}
+
+	// 1. Join the memberships with the user documents for names and emails
+	collection := databases.GetCollection(databases.Client, "users")
+	cursor, err := collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Failed to query users")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	users := []models.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Error parsing user data")
+		return
+	}
+	byID := map[string]models.User{}
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+
+	members := make([]memberView, 0, len(memberships))
+	for _, m := range memberships {
+		u := byID[m.UserID]
+		members = append(members, memberView{UserID: m.UserID, Name: u.Name, Email: u.Email, Role: m.Role, AddedAt: m.AddedAt})
+	}
+
+	utils.SendSuccess(w, "Project members retrieved successfully", members)
+}
+
+func AddProjectMemberHandler(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ProjectID string `json:"projectId" validate:"required"`
+		UserID    string `json:"userId" validate:"required"`
+		Role      string `json:"role" validate:"oneof=viewer|member|maintainer"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	request.ProjectID = pathParam(r, "projectId", request.ProjectID)
+	if request.Role == "" {
+		request.Role = models.ProjectRoleMember
+	}
+	if errs := utils.Validate(request); len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 1. Authorize: maintainers add members and viewers, owners add anyone
+	project, callerRole, ok := authorizeProject(ctx, w, r, request.ProjectID, models.ProjectRoleMaintainer)
+	if !ok {
+		return
+	}
+	if !canManageMember(callerRole, request.Role) {
+		utils.SendError(w, http.StatusForbidden, "You cannot grant the "+request.Role+" role")
+		return
+	}
+
+	// 2. The user must exist and must not already be in the project
+	exists, err := userExists(ctx, request.UserID)
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !exists {
+		var errs utils.ValidationErrors
+		errs.Add("userId", utils.CodeNotFound, "User not found")
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+	if project.RoleOf(request.UserID) != "" {
+		utils.SendError(w, http.StatusConflict, "User is already a member of this project")
+		return
+	}
+
+	// 3. The filter makes a concurrent duplicate add a no-op
+	collection := databases.GetCollection(databases.Client, "projects")
+	member := models.ProjectMember{UserID: request.UserID, Role: request.Role, AddedAt: time.Now()}
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": project.ID, "memberships.userId": bson.M{"$ne": request.UserID}},
+		bson.M{
+			"$push":     bson.M{"memberships": member},
+			"$addToSet": bson.M{"memberIds": request.UserID},
+		},
+	)
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if result.MatchedCount == 0 {
+		utils.SendError(w, http.StatusConflict, "User is already a member of this project")
+		return
+	}
+
+	utils.SendSuccess(w, "Member added", member)
+}
+
+func RemoveProjectMemberHandler(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ProjectID     string `json:"projectId"`
+		UserID        string `json:"userId"`
+		ReassignTo    string `json:"reassignTo"`    // hand their open tasks to this member
+		UnassignTasks bool   `json:"unassignTasks"` // or leave their open tasks unassigned
+	}
+	if err := decodeOptionalBody(r, &request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	request.ProjectID = pathParam(r, "projectId", request.ProjectID)
+	request.UserID = pathParam(r, "userId", request.UserID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 1. Anyone may leave a project; removing others needs a higher role
+	minRole := models.ProjectRoleMaintainer
+	if request.UserID == r.Header.Get("User-ID") {
+		minRole = models.ProjectRoleViewer
+	}
+	project, callerRole, ok := authorizeProject(ctx, w, r, request.ProjectID, minRole)
+	if !ok {
+		return
+	}
+
+	targetRole := project.RoleOf(request.UserID)
+	if targetRole == "" {
+		utils.SendError(w, http.StatusNotFound, "User is not a member of this project")
+		return
+	}
+	if targetRole == models.ProjectRoleOwner {
+		utils.SendError(w, http.StatusBadRequest, "The owner cannot be removed; transfer ownership first")
+		return
+	}
+	if request.UserID != r.Header.Get("User-ID") && !canManageMember(callerRole, targetRole) {
+		utils.SendError(w, http.StatusForbidden, "You cannot remove a "+targetRole)
+		return
+	}
+	if request.ReassignTo != "" {
+		if request.ReassignTo == request.UserID || project.RoleOf(request.ReassignTo) == "" {
+			utils.SendError(w, http.StatusBadRequest, "reassignTo must be another member of the project")
+			return
+		}
+	}
+
+	// 2. Drop the membership and, optionally, hand over or release their open
+	// tasks. Both happen together, so tasks never stay with a non-member.
+	var modified int64
+	var warnings []string
+	err := databases.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		modified, warnings = 0, nil
+		collection := databases.GetCollection(databases.Client, "projects")
+		_, err := collection.UpdateOne(sc, bson.M{"_id": project.ID}, bson.M{
+			"$pull": bson.M{
+				"memberships": bson.M{"userId": request.UserID},
+				"memberIds":   request.UserID,
+			},
+		})
+		if err != nil || (request.ReassignTo == "" && !request.UnassignTasks) {
+			return err
+		}
+
+		taskColl := databases.GetCollection(databases.Client, "tasks")
+		filter := openTasksFilter(project)
+		filter["assignedto"] = request.UserID
+		update := bumpVersion(bson.M{"$set": bson.M{"assignedto": request.ReassignTo, "updatedat": time.Now()}})
+		if request.ReassignTo == "" {
+			result, err := taskColl.UpdateMany(sc, filter, update)
+			if err != nil {
+				return err
+			}
+			modified = result.ModifiedCount
+			return nil
+		}
+
+		// The new assignee's WIP limits apply to every task handed over, so
+		// each is checked and moved in turn and counts against the next
+		cursor, err := taskColl.Find(sc, filter)
+		if err != nil {
+			return err
+		}
+		var tasks []models.Task
+		if err := cursor.All(sc, &tasks); err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			limits := applicableWIPLimits(project, task, task.Status, request.ReassignTo)
+			taskWarnings, err := checkWIPLimits(sc, project, task.ID, request.ReassignTo, limits)
+			if err != nil {
+				return err
+			}
+			warnings = append(warnings, taskWarnings...)
+			result, err := taskColl.UpdateOne(sc, taskIDFilter(task.ID), update)
+			if err != nil {
+				return err
+			}
+			modified += result.ModifiedCount
+		}
+		return nil
+	})
+	if err != nil && !isWIPExceeded(err) {
+		log.Printf("[%s] remove member: %v", r.Header.Get(utils.RequestIDHeader), err)
+	}
+	if sendWIPError(w, err) {
+		return
+	}
+
+	utils.SendSuccess(w, "Member removed", map[string]interface{}{
+		"userId":       request.UserID,
+		"tasksUpdated": modified,
+		"warnings":     warnings,
+	})
+}
+
+func UpdateProjectMemberRoleHandler(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ProjectID string `json:"projectId" validate:"required"`
+		UserID    string `json:"userId" validate:"required"`
+		Role      string `json:"role" validate:"required,oneof=viewer|member|maintainer"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	request.ProjectID = pathParam(r, "projectId", request.ProjectID)
+	request.UserID = pathParam(r, "userId", request.UserID)
+	if errs := utils.Validate(request); len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	project, callerRole, ok := authorizeProject(ctx, w, r, request.ProjectID, models.ProjectRoleMaintainer)
+	if !ok {
+		return
+	}
+
+	// 1. Validate the change against both the old and the new role
+	targetRole := project.RoleOf(request.UserID)
+	if targetRole == "" {
+		utils.SendError(w, http.StatusNotFound, "User is not a member of this project")
+		return
+	}
+	if targetRole == models.ProjectRoleOwner {
+		utils.SendError(w, http.StatusBadRequest, "The owner's role cannot be changed; transfer ownership instead")
+		return
+	}
+	if !canManageMember(callerRole, targetRole) || !canManageMember(callerRole, request.Role) {
+		utils.SendError(w, http.StatusForbidden, "You cannot change this member's role")
+		return
+	}
+
+	// 2. Legacy members listed only in memberIds get a membership record now
+	collection := databases.GetCollection(databases.Client, "projects")
+	filter := bson.M{"_id": project.ID, "memberships.userId": request.UserID}
+	update := bson.M{"$set": bson.M{"memberships.$.role": request.Role}}
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err == nil && result.MatchedCount == 0 {
+		member := models.ProjectMember{UserID: request.UserID, Role: request.Role, AddedAt: time.Now()}
+		_, err = collection.UpdateOne(ctx,
+			bson.M{"_id": project.ID, "memberships.userId": bson.M{"$ne": request.UserID}},
+			bson.M{"$push": bson.M{"memberships": member}},
+		)
+	}
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	utils.SendSuccess(w, "Member role updated", map[string]string{
+		"userId": request.UserID,
+		"role":   request.Role,
+	})
+}
+
+func TransferProjectOwnershipHandler(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ProjectID string `json:"projectId"`
+		UserID    string `json:"userId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	request.ProjectID = pathParam(r, "projectId", request.ProjectID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	project, _, ok := authorizeProject(ctx, w, r, request.ProjectID, models.ProjectRoleOwner)
+	if !ok {
+		return
+	}
+
+	// 1. The new owner has to be in the project already
+	if request.UserID == project.OwnerID {
+		utils.SendError(w, http.StatusBadRequest, "User already owns this project")
+		return
+	}
+	if project.RoleOf(request.UserID) == "" {
+		utils.SendError(w, http.StatusBadRequest, "The new owner must be a member of the project")
+		return
+	}
+
+	// 2. Swap roles: the new owner is promoted, the old owner stays on as maintainer
+	memberships := allMemberships(project)
+	memberIDs := []string{}
+	for i := range memberships {
+		switch memberships[i].UserID {
+		case request.UserID:
+			memberships[i].Role = models.ProjectRoleOwner
+		case project.OwnerID:
+			memberships[i].Role = models.ProjectRoleMaintainer
+		}
+		if memberships[i].UserID != request.UserID {
+			memberIDs = append(memberIDs, memberships[i].UserID)
+		}
+	}
+
+	// 3. Guard on the current owner so two concurrent transfers cannot both win
+	collection := databases.GetCollection(databases.Client, "projects")
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": project.ID, "ownerId": project.OwnerID},
+		bson.M{"$set": bson.M{
+			"ownerId":     request.UserID,
+			"memberships": memberships,
+			"memberIds":   memberIDs,
+		}},
+	)
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if result.MatchedCount == 0 {
+		utils.SendError(w, http.StatusConflict, "Project ownership changed concurrently, please retry")
+		return
+	}
+
+	utils.SendSuccess(w, "Ownership transferred", map[string]string{
+		"projectId": project.ID,
+		"ownerId":   request.UserID,
+	})
+}
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"trello-lite/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// listSpec describes how a list endpoint can be sorted. Sort keys are the
+// public names accepted in ?sort=, mapped to the stored field; a leading "-"
+// on the key sorts descending.
+type listSpec struct {
+	sorts       map[string]string
+	defaultSort string
+	// computed holds $addFields expressions for sort fields that are not stored
+	computed map[string]interface{}
+}
+
+// taskListSpec is shared by the task list and search endpoints
+var taskListSpec = listSpec{
+	sorts: map[string]string{
+		"rank":      "rank",
+		"dueDate":   "duedate",
+		"priority":  "priorityRank",
+		"updatedAt": "updatedat",
+		"createdAt": "createdat",
+	},
+	defaultSort: "rank",
+	computed: map[string]interface{}{
+		// Priorities sort by importance, not alphabetically
+		"priorityRank": bson.M{"$switch": bson.M{
+			"branches": bson.A{
+				bson.M{"case": bson.M{"$eq": bson.A{"$priority", "Low"}}, "then": 1},
+				bson.M{"case": bson.M{"$eq": bson.A{"$priority", "Medium"}}, "then": 2},
+				bson.M{"case": bson.M{"$eq": bson.A{"$priority", "High"}}, "then": 3},
+				bson.M{"case": bson.M{"$eq": bson.A{"$priority", "Urgent"}}, "then": 4},
+			},
+			"default": 0,
+		}},
+	},
+}
+
+// taskSearchSpec sorts search results most recently changed first, since
+// rank only means something within one project
+var taskSearchSpec = listSpec{
+	sorts:       taskListSpec.sorts,
+	defaultSort: "-updatedAt",
+	computed:    taskListSpec.computed,
+}
+
+// withSorts returns a copy of the spec that also accepts the given sort keys
+func (s listSpec) withSorts(extra map[string]string) listSpec {
+	sorts := make(map[string]string, len(s.sorts)+len(extra))
+	for k, v := range s.sorts {
+		sorts[k] = v
+	}
+	for k, v := range extra {
+		sorts[k] = v
+	}
+	s.sorts = sorts
+	return s
+}
+
+var userListSpec = listSpec{
+	sorts: map[string]string{
+		"createdAt": "createdAt",
+		"name":      "name",
+		"email":     "email",
+	},
+	defaultSort: "createdAt",
+}
+
+var projectListSpec = listSpec{
+	sorts: map[string]string{
+		"createdAt": "createdAt",
+		"updatedAt": "updatedAt",
+		"name":      "name",
+	},
+	defaultSort: "createdAt",
+}
+
+// pageRequest is a parsed ?limit=&sort=&cursor=
+type pageRequest struct {
+	limit int
+	sort  string // public key as given, e.g. "-dueDate"
+	field string
+	desc  bool
+	after *pageCursor
+	spec  listSpec
+}
+
+// pageCursor is the position after the last item of a page. It is sent to
+// clients as opaque base64 so its layout can change without breaking them.
+type pageCursor struct {
+	Sort  string      `bson:"s"`
+	Value interface{} `bson:"v"`
+	ID    interface{} `bson:"id"`
+}
+
+// parsePageRequest reads the paging query parameters, recording any problem in errs
+func parsePageRequest(r *http.Request, spec listSpec, errs *utils.ValidationErrors) pageRequest {
+	query := r.URL.Query()
+	p := pageRequest{limit: defaultPageSize, sort: spec.defaultSort, spec: spec}
+
+	if s := query.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			errs.Add("limit", utils.CodeInvalidFormat, "limit must be a number from 1 to "+strconv.Itoa(maxPageSize))
+		} else {
+			p.limit = limit
+		}
+	}
+
+	if s := query.Get("sort"); s != "" {
+		p.sort = s
+	}
+	key := strings.TrimPrefix(p.sort, "-")
+	p.desc = strings.HasPrefix(p.sort, "-")
+	field, ok := spec.sorts[key]
+	if !ok {
+		keys := make([]string, 0, len(spec.sorts))
+		for k := range spec.sorts {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		errs.Add("sort", utils.CodeInvalidChoice, "sort must be one of "+strings.Join(keys, ", ")+", optionally prefixed with -")
+	}
+	p.field = field
+
+	if s := query.Get("cursor"); s != "" {
+		after, err := decodePageCursor(s)
+		switch {
+		case err != nil:
+			errs.Add("cursor", utils.CodeInvalidFormat, "cursor is not valid")
+		case after.Sort != p.sort:
+			errs.Add("cursor", utils.CodeConflict, "cursor was issued for sort "+after.Sort+", not "+p.sort)
+		default:
+			p.after = &after
+		}
+	}
+	return p
+}
+
+// stages returns the pipeline stages that select one page. They go after
+// the list's own $match so its indexes are used for filtering.
+func (p pageRequest) stages() []bson.D {
+	var stages []bson.D
+	if expr, ok := p.spec.computed[p.field]; ok {
+		stages = append(stages, bson.D{{Key: "$addFields", Value: bson.M{p.field: expr}}})
+	}
+	if p.after != nil {
+		stages = append(stages, bson.D{{Key: "$match", Value: p.afterFilter()}})
+	}
+	stages = append(stages,
+		p.sortStage(),
+		// One extra document tells us whether there is a next page
+		bson.D{{Key: "$limit", Value: p.limit + 1}},
+	)
+	return stages
+}
+
+// sortStages returns the stages that put a whole list in the requested
+// order, ignoring limit and cursor
+func (p pageRequest) sortStages() []bson.D {
+	var stages []bson.D
+	if expr, ok := p.spec.computed[p.field]; ok {
+		stages = append(stages, bson.D{{Key: "$addFields", Value: bson.M{p.field: expr}}})
+	}
+	return append(stages, p.sortStage())
+}
+
+func (p pageRequest) sortStage() bson.D {
+	dir := 1
+	if p.desc {
+		dir = -1
+	}
+	return bson.D{{Key: "$sort", Value: bson.D{{Key: p.field, Value: dir}, {Key: "_id", Value: dir}}}}
+}
+
+// afterFilter matches documents that sort after the cursor. Missing and null
+// values sort before everything else, so they need their own branches.
+func (p pageRequest) afterFilter() bson.M {
+	f, v, id := p.field, p.after.Value, p.after.ID
+	op := "$gt"
+	if p.desc {
+		op = "$lt"
+	}
+
+	if v == nil {
+		if p.desc {
+			return bson.M{f: nil, "_id": afterID(id, p.desc)}
+		}
+		return bson.M{"$or": bson.A{
+			bson.M{f: nil, "_id": afterID(id, p.desc)},
+			bson.M{f: bson.M{"$ne": nil}},
+		}}
+	}
+
+	branches := bson.A{
+		bson.M{f: bson.M{op: v}},
+		bson.M{f: v, "_id": afterID(id, p.desc)},
+	}
+	if p.desc {
+		branches = append(branches, bson.M{f: nil})
+	}
+	return bson.M{"$or": branches}
+}
+
+// afterID compares _id values. Older tasks have ObjectId IDs, which sort
+// after every string ID; $not also matches IDs of the other type, so paging
+// crosses from strings to ObjectIds (or back, descending).
+func afterID(id interface{}, desc bool) bson.M {
+	if desc {
+		return bson.M{"$not": bson.M{"$gte": id}}
+	}
+	return bson.M{"$not": bson.M{"$lte": id}}
+}
+
+// readPage decodes one page of results into out (a pointer to a slice) and
+// describes where the next page starts
+func readPage(ctx context.Context, cursor *mongo.Cursor, p pageRequest, out interface{}) (utils.Page, error) {
+	page := utils.Page{Limit: p.limit}
+
+	var docs []bson.Raw
+	for cursor.Next(ctx) {
+		docs = append(docs, append(bson.Raw(nil), cursor.Current...))
+	}
+	if err := cursor.Err(); err != nil {
+		return page, err
+	}
+
+	if len(docs) > p.limit {
+		docs = docs[:p.limit]
+		last := docs[len(docs)-1]
+		next := pageCursor{Sort: p.sort, ID: rawValue(last.Lookup("_id"))}
+		// Sort fields can be nested, like customFields.<id>
+		next.Value = rawValue(last.Lookup(strings.Split(p.field, ".")...))
+		encoded, err := encodePageCursor(next)
+		if err != nil {
+			return page, err
+		}
+		page.HasMore = true
+		page.NextCursor = encoded
+	}
+
+	items := reflect.ValueOf(out).Elem()
+	items.Set(reflect.MakeSlice(items.Type(), 0, len(docs)))
+	for _, doc := range docs {
+		item := reflect.New(items.Type().Elem())
+		if err := bson.Unmarshal(doc, item.Interface()); err != nil {
+			return page, err
+		}
+		items.Set(reflect.Append(items, item.Elem()))
+	}
+	return page, nil
+}
+
+// rawValue converts a looked-up value for the cursor; missing becomes nil
+func rawValue(v bson.RawValue) interface{} {
+	if v.Type == 0 || v.Type == bsontype.Null {
+		return nil
+	}
+	return v
+}
+
+func encodePageCursor(c pageCursor) (string, error) {
+	data, err := bson.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return utils.SignCursor(data)
+}
+
+// cursorValueTypes are the BSON types a cursor position may hold. Documents
+// and arrays are refused, since in a filter they could carry query operators.
+var cursorValueTypes = map[bsontype.Type]bool{
+	0:                   true, // missing
+	bsontype.Null:       true,
+	bsontype.String:     true,
+	bsontype.Double:     true,
+	bsontype.Int32:      true,
+	bsontype.Int64:      true,
+	bsontype.Decimal128: true,
+	bsontype.Boolean:    true,
+	bsontype.DateTime:   true,
+	bsontype.Timestamp:  true,
+	bsontype.ObjectID:   true,
+}
+
+func decodePageCursor(s string) (pageCursor, error) {
+	data, err := utils.VerifyCursor(s)
+	if err != nil {
+		return pageCursor{}, err
+	}
+	var raw struct {
+		Sort  string        `bson:"s"`
+		Value bson.RawValue `bson:"v"`
+		ID    bson.RawValue `bson:"id"`
+	}
+	if err := bson.Unmarshal(data, &raw); err != nil {
+		return pageCursor{}, err
+	}
+	if !cursorValueTypes[raw.Value.Type] || !cursorValueTypes[raw.ID.Type] || rawValue(raw.ID) == nil {
+		return pageCursor{}, errors.New("cursor holds an unsupported value")
+	}
+	return pageCursor{Sort: raw.Sort, Value: rawValue(raw.Value), ID: rawValue(raw.ID)}, nil
+}
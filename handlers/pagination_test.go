@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"trello-lite/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+func TestMain(m *testing.M) {
+	if err := utils.LoadCursorKey(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// rawOf wraps a Go value the way readPage finds it in a result document
+func rawOf(t *testing.T, v interface{}) bson.RawValue {
+	t.Helper()
+	typ, data, err := bson.MarshalValue(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bson.RawValue{Type: typ, Value: data}
+}
+
+// extJSON renders a cursor value for comparison
+func extJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	out, err := bson.MarshalExtJSON(bson.M{"f": v}, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestPageCursorRoundTrip(t *testing.T) {
+	due := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{"string", "0i"},
+		{"date", due},
+		{"number", 3.5},
+		{"missing", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := pageCursor{Sort: "-dueDate", ID: rawOf(t, "t1")}
+			if tt.value != nil {
+				c.Value = rawOf(t, tt.value)
+			}
+			encoded, err := encodePageCursor(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := decodePageCursor(encoded)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if got.Sort != c.Sort || extJSON(t, got.Value) != extJSON(t, c.Value) || extJSON(t, got.ID) != extJSON(t, c.ID) {
+				t.Errorf("round trip = %+v, want %+v", got, c)
+			}
+		})
+	}
+}
+
+func TestPageCursorRejected(t *testing.T) {
+	signed := func(doc bson.M) string {
+		data, err := bson.Marshal(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := utils.SignCursor(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	valid, _ := encodePageCursor(pageCursor{Sort: "rank", Value: rawOf(t, "0i"), ID: rawOf(t, "t1")})
+	data, sig, _ := strings.Cut(valid, ".")
+	forged, _ := bson.Marshal(bson.M{"s": "rank", "v": bson.M{"$ne": nil}, "id": "t1"})
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "!!!"},
+		{"unsigned", data},
+		{"bad signature", data + "." + sig[:len(sig)-2] + "AA"},
+		{"signature of other data", strings.TrimSuffix(valid, sig) + sig[1:]},
+		{"forged payload", base64.RawURLEncoding.EncodeToString(forged) + "." + sig},
+		{"operator value", signed(bson.M{"s": "rank", "v": bson.M{"$ne": nil}, "id": "t1"})},
+		{"regex value", signed(bson.M{"s": "rank", "v": bson.M{"$regex": ".*"}, "id": "t1"})},
+		{"array value", signed(bson.M{"s": "rank", "v": bson.A{"a"}, "id": "t1"})},
+		{"operator id", signed(bson.M{"s": "rank", "v": "0i", "id": bson.M{"$gt": ""}})},
+		{"missing id", signed(bson.M{"s": "rank", "v": "0i"})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if c, err := decodePageCursor(tt.cursor); err == nil {
+				t.Errorf("decodePageCursor accepted %+v", c)
+			}
+		})
+	}
+}
+
+func TestAfterFilter(t *testing.T) {
+	id := rawOf(t, "t1")
+	v := rawOf(t, "0i")
+	tests := []struct {
+		name  string
+		desc  bool
+		value interface{}
+		want  interface{}
+	}{
+		{"ascending", false, v, bson.M{"$or": bson.A{
+			bson.M{"rank": bson.M{"$gt": v}},
+			bson.M{"rank": v, "_id": bson.M{"$not": bson.M{"$lte": id}}},
+		}}},
+		{"descending includes missing values", true, v, bson.M{"$or": bson.A{
+			bson.M{"rank": bson.M{"$lt": v}},
+			bson.M{"rank": v, "_id": bson.M{"$not": bson.M{"$gte": id}}},
+			bson.M{"rank": nil},
+		}}},
+		{"ascending from a missing value", false, nil, bson.M{"$or": bson.A{
+			bson.M{"rank": nil, "_id": bson.M{"$not": bson.M{"$lte": id}}},
+			bson.M{"rank": bson.M{"$ne": nil}},
+		}}},
+		{"descending from a missing value", true, nil,
+			bson.M{"rank": nil, "_id": bson.M{"$not": bson.M{"$gte": id}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := pageRequest{field: "rank", desc: tt.desc, after: &pageCursor{Value: tt.value, ID: id}}
+			if got := p.afterFilter(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("afterFilter()\n got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePageRequest(t *testing.T) {
+	cursor, _ := encodePageCursor(pageCursor{Sort: "rank", Value: rawOf(t, "0i"), ID: rawOf(t, "t1")})
+	tests := []struct {
+		query     string
+		field     string
+		desc      bool
+		limit     int
+		errs      []string
+		hasCursor bool
+	}{
+		{"", "rank", false, defaultPageSize, nil, false},
+		{"sort=-dueDate&limit=10", "duedate", true, 10, nil, false},
+		{"sort=priority", "priorityRank", false, defaultPageSize, nil, false},
+		{"limit=0", "rank", false, defaultPageSize, []string{"limit"}, false},
+		{"limit=201", "rank", false, defaultPageSize, []string{"limit"}, false},
+		{"sort=title", "", false, defaultPageSize, []string{"sort"}, false},
+		{"cursor=" + cursor, "rank", false, defaultPageSize, nil, true},
+		{"sort=-rank&cursor=" + cursor, "rank", true, defaultPageSize, []string{"cursor"}, false},
+		{"cursor=abc", "rank", false, defaultPageSize, []string{"cursor"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			var errs utils.ValidationErrors
+			p := parsePageRequest(httptest.NewRequest("GET", "/x?"+tt.query, nil), taskListSpec, &errs)
+			var fields []string
+			for _, e := range errs {
+				fields = append(fields, e.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.errs, ",") {
+				t.Errorf("errors on %v, want %v", fields, tt.errs)
+			}
+			if p.field != tt.field || p.desc != tt.desc || p.limit != tt.limit || (p.after != nil) != tt.hasCursor {
+				t.Errorf("got field=%q desc=%v limit=%d cursor=%v", p.field, p.desc, p.limit, p.after != nil)
+			}
+		})
+	}
+}
+
+func TestRawValue(t *testing.T) {
+	if rawValue(bson.RawValue{}) != nil || rawValue(bson.RawValue{Type: bsontype.Null}) != nil {
+		t.Error("missing and null values should become nil")
+	}
+}
@@ -3,6 +3,8 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
 	"time"
 	"trello-lite/databases"
@@ -10,35 +12,108 @@ import (
 	"trello-lite/utils"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func CreateProjectHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	var newProj models.Project
+	if err := json.NewDecoder(r.Body).Decode(&newProj); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	var newProj models.Project
-	if err := json.NewDecoder(r.Body).Decode(&newProj); err != nil {
-		http.Error(w, "Invalid data", http.StatusBadRequest)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The board, workflow and WIP limits must fit together, and every listed
+	// member must be a real account
+	errs := utils.Validate(newProj)
+	if newProj.Workflow != nil && len(errs) == 0 {
+		if msg := validateWorkflow(*newProj.Workflow); msg != "" {
+			errs.Add("workflow", utils.CodeInvalidFormat, msg)
+		}
+	}
+	workflow := newProj.EffectiveWorkflow()
+	for i, c := range newProj.Columns {
+		if c.Status != "" && !workflow.HasStatus(c.Status) {
+			errs.Add(fmt.Sprintf("columns[%d].status", i), utils.CodeInvalidChoice, "Unknown status \""+c.Status+"\" for this project")
+		}
+	}
+	for i, l := range newProj.WIPLimits {
+		if l.Status != "" && !workflow.HasStatus(l.Status) {
+			errs.Add(fmt.Sprintf("wipLimits[%d].status", i), utils.CodeInvalidChoice, "Unknown status \""+l.Status+"\" for this project")
+		}
+	}
+	// A project can start with a label catalog; each label gets a fresh ID
+	for i := range newProj.Labels {
+		label := &newProj.Labels[i]
+		label.ID = primitive.NewObjectID().Hex()
+		if color, ok := models.NormalizeLabelColor(label.Color); ok {
+			label.Color = color
+		} else if label.Color != "" {
+			errs.Add(fmt.Sprintf("labels[%d].color", i), utils.CodeInvalidFormat, "color must be a hex color such as #d73a4a")
+		}
+		if other, ok := newProj.LabelNamed(label.Name); ok && other.ID != label.ID {
+			errs.Add(fmt.Sprintf("labels[%d].name", i), utils.CodeConflict, "Duplicate label name \""+label.Name+"\"")
+		}
+	}
+	newProj.CustomFields = checkNewCustomFields(newProj.CustomFields, &errs)
+	for i, id := range newProj.MemberIDs {
+		if id == "" {
+			continue
+		}
+		if exists, err := userExists(ctx, id); err != nil {
+			utils.SendError(w, http.StatusInternalServerError, "Database error")
+			return
+		} else if !exists {
+			errs.Add(fmt.Sprintf("memberIds[%d]", i), utils.CodeNotFound, "User "+id+" does not exist")
+		}
+	}
+	if len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
 		return
 	}
 
+	// The caller always owns the project they create; listed members start as plain members
+	callerID := r.Header.Get("User-ID")
+	newProj.ID = primitive.NewObjectID().Hex()
+	newProj.OwnerID = callerID
 	newProj.CreatedAt = time.Now()
+	newProj.UpdatedAt = newProj.CreatedAt
+	newProj.Archived = false
+	newProj.ArchivedAt = nil
+	newProj.Memberships = []models.ProjectMember{
+		{UserID: callerID, Role: models.ProjectRoleOwner, AddedAt: newProj.CreatedAt},
+	}
+	memberIDs := []string{}
+	for _, id := range newProj.MemberIDs {
+		if id == "" || id == callerID {
+			continue
+		}
+		memberIDs = append(memberIDs, id)
+		newProj.Memberships = append(newProj.Memberships, models.ProjectMember{
+			UserID: id, Role: models.ProjectRoleMember, AddedAt: newProj.CreatedAt,
+		})
+	}
+	newProj.MemberIDs = memberIDs
+	if len(newProj.Columns) == 0 {
+		newProj.Columns = models.DefaultColumns()
+	}
+	for i := range newProj.Columns {
+		if newProj.Columns[i].ID == "" {
+			newProj.Columns[i].ID = primitive.NewObjectID().Hex()
+		}
+	}
 	collection := databases.GetCollection(databases.Client, "projects")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
 	_, err := collection.InsertOne(ctx, newProj)
 	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Project created!"})
+	utils.SendCreated(w, "Project created!", map[string]string{"id": newProj.ID})
 }
 
 func GetMyProjectsHandler(w http.ResponseWriter, r *http.Request) {
@@ -51,48 +126,87 @@ func GetMyProjectsHandler(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	matchCriteria := bson.M{}
-	if role != "Super Admin" {
+	if role != models.RoleSuperAdmin {
 		matchCriteria = bson.M{
 			"$or": []bson.M{
 				{"ownerId": userID},
 				{"memberIds": userID},
+				{"memberships.userId": userID},
 			},
 		}
 	}
 
-	pipeline := mongo.Pipeline{
-		{{Key: "$match", Value: matchCriteria}},
+	// Archived projects are hidden unless asked for; ?archived=only lists just those
+	switch r.URL.Query().Get("archived") {
+	case "include":
+	case "only":
+		matchCriteria["archived"] = true
+	default:
+		matchCriteria["archived"] = bson.M{"$ne": true}
+	}
 
-		{{Key: "$lookup", Value: bson.M{
+	var errs utils.ValidationErrors
+	page := parsePageRequest(r, projectListSpec, &errs)
+	if len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	// Page first, so members are only looked up for the projects returned
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: matchCriteria}}}
+	pipeline = append(pipeline, page.stages()...)
+	pipeline = append(pipeline,
+		bson.D{{Key: "$lookup", Value: bson.M{
 			"from":         "users",
 			"localField":   "memberIds",
-			"foreignField": "id",
+			"foreignField": "_id",
 			"as":           "members",
 		}}},
 
-		{{Key: "$addFields", Value: bson.M{
+		bson.D{{Key: "$addFields", Value: bson.M{
 			"id": "$_id",
 		}}},
-	}
+	)
 
 	cursor, err := projectColl.Aggregate(ctx, pipeline)
 	if err != nil {
-		http.Error(w, "Query error: "+err.Error(), http.StatusInternalServerError)
+		log.Printf("[%s] list projects: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Error fetching projects")
 		return
 	}
 	defer cursor.Close(ctx)
 
-	responseList := make([]models.ProjectDetailResponse, 0)
-	if err := cursor.All(ctx, &responseList); err != nil {
-		http.Error(w, "Decoding error: "+err.Error(), http.StatusInternalServerError)
+	var responseList []models.ProjectDetailResponse
+	info, err := readPage(ctx, cursor, page, &responseList)
+	if err != nil {
+		log.Printf("[%s] decode projects: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Data format error")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(responseList)
+	utils.SendPage(w, "Projects retrieved successfully", responseList, info)
+}
+
+func GetProjectHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Every project role can read the project itself
+	project, _, ok := authorizeProject(ctx, w, r, r.PathValue("projectId"), models.ProjectRoleViewer)
+	if !ok {
+		return
+	}
+
+	utils.SendSuccess(w, "Project retrieved successfully", project)
 }
 
 func GetEverythingAggregateHandler(w http.ResponseWriter, r *http.Request) {
+	// Dumps every project on the instance, so it is not available to team Admins
+	if r.Header.Get("Role") != models.RoleSuperAdmin {
+		utils.SendError(w, http.StatusForbidden, "Access denied: Super Admin privileges required")
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
@@ -135,3 +249,145 @@ func GetEverythingAggregateHandler(w http.ResponseWriter, r *http.Request) {
 	// 2. Use your utils to send the data
 	utils.SendSuccess(w, "All system data retrieved successfully", content)
 }
+
+func UpdateProjectHandler(w http.ResponseWriter, r *http.Request) {
+	// Pointers tell "not sent" apart from "set to empty"
+	var request struct {
+		ProjectID   string  `json:"projectId" validate:"required"`
+		Name        *string `json:"name" validate:"required,max=100"`
+		Description *string `json:"description" validate:"max=2000"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	request.ProjectID = pathParam(r, "projectId", request.ProjectID)
+	if errs := utils.Validate(request); len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	project, _, ok := authorizeProject(ctx, w, r, request.ProjectID, models.ProjectRoleMaintainer)
+	if !ok {
+		return
+	}
+
+	set := bson.M{"updatedAt": time.Now()}
+	if request.Name != nil {
+		set["name"] = *request.Name
+	}
+	if request.Description != nil {
+		set["description"] = *request.Description
+	}
+
+	collection := databases.GetCollection(databases.Client, "projects")
+	if _, err := collection.UpdateOne(ctx, bson.M{"_id": project.ID}, bson.M{"$set": set}); err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	utils.SendSuccess(w, "Project updated", nil)
+}
+
+// setProjectArchived implements both archive and restore
+func setProjectArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	var request struct {
+		ProjectID string `json:"projectId"`
+	}
+	if err := decodeOptionalBody(r, &request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	request.ProjectID = pathParam(r, "projectId", request.ProjectID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	project, _, ok := authorizeProjectLifecycle(ctx, w, r, request.ProjectID, models.ProjectRoleOwner)
+	if !ok {
+		return
+	}
+	if project.Archived == archived {
+		utils.SendError(w, http.StatusConflict, "Project is already in that state")
+		return
+	}
+
+	now := time.Now()
+	update := bson.M{"$set": bson.M{"archived": true, "archivedAt": now, "updatedAt": now}}
+	message := "Project archived"
+	if !archived {
+		update = bson.M{"$set": bson.M{"archived": false, "updatedAt": now}, "$unset": bson.M{"archivedAt": ""}}
+		message = "Project restored"
+	}
+
+	collection := databases.GetCollection(databases.Client, "projects")
+	if _, err := collection.UpdateOne(ctx, bson.M{"_id": project.ID}, update); err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	utils.SendSuccess(w, message, nil)
+}
+
+func ArchiveProjectHandler(w http.ResponseWriter, r *http.Request) {
+	setProjectArchived(w, r, true)
+}
+
+func RestoreProjectHandler(w http.ResponseWriter, r *http.Request) {
+	setProjectArchived(w, r, false)
+}
+
+// deleteProjectData removes a project and everything that hangs off it. It
+// must run inside a transaction so a failure never leaves orphaned tasks.
+func deleteProjectData(sc mongo.SessionContext, projectID string) (int64, error) {
+	tasks, err := databases.GetCollection(databases.Client, "tasks").DeleteMany(sc, bson.M{"projectid": projectID})
+	if err != nil {
+		return 0, err
+	}
+	if _, err := databases.GetCollection(databases.Client, "invitations").DeleteMany(sc, bson.M{"projectId": projectID}); err != nil {
+		return 0, err
+	}
+	if _, err := databases.GetCollection(databases.Client, "comments").DeleteMany(sc, bson.M{"projectId": projectID}); err != nil {
+		return 0, err
+	}
+	// Links from other projects' tasks into this one go too
+	links := bson.M{"$or": []bson.M{{"fromProjectId": projectID}, {"toProjectId": projectID}}}
+	if _, err := databases.GetCollection(databases.Client, "task_links").DeleteMany(sc, links); err != nil {
+		return 0, err
+	}
+	if _, err := databases.GetCollection(databases.Client, "projects").DeleteOne(sc, bson.M{"_id": projectID}); err != nil {
+		return 0, err
+	}
+	return tasks.DeletedCount, nil
+}
+
+func DeleteProjectHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	project, _, ok := authorizeProjectLifecycle(ctx, w, r, pathParam(r, "projectId", r.URL.Query().Get("id")), models.ProjectRoleOwner)
+	if !ok {
+		return
+	}
+
+	// The project and all of its data go together, or not at all
+	var deletedTasks int64
+	err := databases.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		var err error
+		deletedTasks, err = deleteProjectData(sc, project.ID)
+		return err
+	})
+	if err != nil {
+		log.Printf("[%s] delete project: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Delete failed")
+		return
+	}
+
+	utils.SendSuccess(w, "Project deleted", map[string]interface{}{
+		"id":           project.ID,
+		"deletedTasks": deletedTasks,
+	})
+}
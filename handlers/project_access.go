@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"trello-lite/databases"
+	"trello-lite/models"
+	"trello-lite/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// pathParam returns the named {wildcard} of a resource route. Legacy routes
+// carry the same value in the query string or body, passed as fallback.
+func pathParam(r *http.Request, name, fallback string) string {
+	if value := r.PathValue(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// decodeOptionalBody decodes a JSON body that may be left out entirely, for
+// resource routes whose IDs all come from the path
+func decodeOptionalBody(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// callerProjectRole returns the caller's role in project. Super Admins manage
+// the whole instance and act as owners of every project; the global Admin role
+// grants nothing inside a project the caller is not a member of.
+func callerProjectRole(r *http.Request, project models.Project) string {
+	if r.Header.Get("Role") == models.RoleSuperAdmin {
+		return models.ProjectRoleOwner
+	}
+	return project.RoleOf(r.Header.Get("User-ID"))
+}
+
+// authorizeProject loads the project and checks the caller holds at least
+// minRole in it. On failure it writes the error response and returns ok=false.
+// Projects the caller cannot see at all are reported as not found, and
+// archived projects are read-only: anything above viewer is refused.
+func authorizeProject(ctx context.Context, w http.ResponseWriter, r *http.Request, projectID, minRole string) (models.Project, string, bool) {
+	project, role, ok := authorizeProjectLifecycle(ctx, w, r, projectID, minRole)
+	if ok && project.Archived && minRole != models.ProjectRoleViewer {
+		utils.SendErrorCode(w, http.StatusConflict, utils.ErrProjectArchived, "Project is archived and read-only")
+		return project, role, false
+	}
+	return project, role, ok
+}
+
+// authorizeProjectLifecycle is authorizeProject without the archived check,
+// for the endpoints that archive, restore and delete projects.
+func authorizeProjectLifecycle(ctx context.Context, w http.ResponseWriter, r *http.Request, projectID, minRole string) (models.Project, string, bool) {
+	project, role, code, msg := loadProjectForCaller(ctx, r, projectID, minRole)
+	if code != 0 {
+		utils.SendError(w, code, msg)
+		return project, role, false
+	}
+	return project, role, true
+}
+
+// loadProjectForCaller loads the project and the caller's role in it. It
+// returns 0 when the caller holds at least minRole, otherwise the HTTP status
+// and message to send; 404 means the project does not exist for the caller.
+func loadProjectForCaller(ctx context.Context, r *http.Request, projectID, minRole string) (models.Project, string, int, string) {
+	var project models.Project
+	if projectID == "" {
+		return project, "", http.StatusBadRequest, "Missing projectId"
+	}
+
+	collection := databases.GetCollection(databases.Client, "projects")
+	err := collection.FindOne(ctx, bson.M{"_id": projectID}).Decode(&project)
+	if err == mongo.ErrNoDocuments {
+		return project, "", http.StatusNotFound, "Project not found"
+	}
+	if err != nil {
+		return project, "", http.StatusInternalServerError, "Database error"
+	}
+
+	role := callerProjectRole(r, project)
+	if role == "" {
+		return project, "", http.StatusNotFound, "Project not found"
+	}
+	if !models.ProjectRoleAtLeast(role, minRole) {
+		return project, role, http.StatusForbidden, "Requires project role " + minRole + " or higher"
+	}
+	return project, role, 0, ""
+}
+
+// visibleProjectIDs lists the projects the caller belongs to. all=true means
+// the caller is a Super Admin and can see every project.
+func visibleProjectIDs(ctx context.Context, r *http.Request) (ids []string, all bool, err error) {
+	if r.Header.Get("Role") == models.RoleSuperAdmin {
+		return nil, true, nil
+	}
+
+	userID := r.Header.Get("User-ID")
+	collection := databases.GetCollection(databases.Client, "projects")
+	filter := bson.M{"$or": []bson.M{
+		{"ownerId": userID},
+		{"memberIds": userID},
+		{"memberships.userId": userID},
+	}}
+	values, err := collection.Distinct(ctx, "_id", filter)
+	if err != nil {
+		return nil, false, err
+	}
+
+	ids = make([]string, 0, len(values))
+	for _, v := range values {
+		if id, ok := v.(string); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, false, nil
+}
+
+// taskIDFilter matches a task by its ID. Tasks created before IDs were
+// generated server-side may have an ObjectId instead of a string _id.
+func taskIDFilter(id string) bson.M {
+	if objID, err := primitive.ObjectIDFromHex(id); err == nil {
+		return bson.M{"_id": bson.M{"$in": bson.A{id, objID}}}
+	}
+	return bson.M{"_id": id}
+}
+
+// taskIDsFilter is taskIDFilter for several tasks at once
+func taskIDsFilter(ids []string) bson.M {
+	values := bson.A{}
+	for _, id := range ids {
+		values = append(values, id)
+		if objID, err := primitive.ObjectIDFromHex(id); err == nil {
+			values = append(values, objID)
+		}
+	}
+	return bson.M{"_id": bson.M{"$in": values}}
+}
+
+// authorizeTask loads a task and checks the caller's role in its project.
+// It writes the error response and returns ok=false on failure.
+func authorizeTask(ctx context.Context, w http.ResponseWriter, r *http.Request, taskID, minRole string) (models.Task, models.Project, string, bool) {
+	var task models.Task
+	if taskID == "" {
+		utils.SendError(w, http.StatusBadRequest, "Missing task id")
+		return task, models.Project{}, "", false
+	}
+
+	collection := databases.GetCollection(databases.Client, "tasks")
+	err := collection.FindOne(ctx, taskIDFilter(taskID)).Decode(&task)
+	if err == mongo.ErrNoDocuments {
+		utils.SendError(w, http.StatusNotFound, "Task not found")
+		return task, models.Project{}, "", false
+	}
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return task, models.Project{}, "", false
+	}
+
+	// Under /projects/{projectId}/tasks/{taskId} the task must belong to that project
+	if projectID := r.PathValue("projectId"); projectID != "" && projectID != task.ProjectId {
+		utils.SendError(w, http.StatusNotFound, "Task not found")
+		return task, models.Project{}, "", false
+	}
+
+	project, role, ok := authorizeProject(ctx, w, r, task.ProjectId, minRole)
+	return task, project, role, ok
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+	"trello-lite/databases"
+	"trello-lite/models"
+	"trello-lite/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// addProgress fills in Progress for tasks of one project: their checklist
+// and their direct subtasks, counted as done by the project's workflow.
+// Tasks with neither keep a nil Progress.
+func addProgress(ctx context.Context, project models.Project, tasks []models.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]string, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+	}
+
+	// 1. Count the subtasks of every task in one query, per status
+	collection := databases.GetCollection(databases.Client, "tasks")
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"parentId": bson.M{"$in": ids}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{"parent": "$parentId", "status": "$status"},
+			"n":   bson.M{"$sum": 1},
+		}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var counts []struct {
+		ID struct {
+			Parent string `bson:"parent"`
+			Status string `bson:"status"`
+		} `bson:"_id"`
+		N int `bson:"n"`
+	}
+	if err := cursor.All(ctx, &counts); err != nil {
+		return err
+	}
+
+	workflow := project.EffectiveWorkflow()
+	subtasks := map[string]models.Progress{}
+	for _, c := range counts {
+		p := subtasks[c.ID.Parent]
+		p.Total += c.N
+		if workflow.IsDone(c.ID.Status) {
+			p.Done += c.N
+		}
+		subtasks[c.ID.Parent] = p
+	}
+
+	// 2. Combine with each task's checklist
+	for i, t := range tasks {
+		progress := models.TaskProgress{Checklist: models.ChecklistProgress(t.Checklist), Subtasks: subtasks[t.ID]}
+		progress.Done = progress.Checklist.Done + progress.Subtasks.Done
+		progress.Total = progress.Checklist.Total + progress.Subtasks.Total
+		if progress.Total > 0 {
+			tasks[i].Progress = &progress
+		}
+	}
+	return nil
+}
+
+// checkChildrenDone enforces the workflow's RequireChildrenDone: a task only
+// moves to a done status once its subtasks are done and its checklist is
+// checked off. It returns 0 when the move may go ahead, like checkTransition.
+func checkChildrenDone(ctx context.Context, project models.Project, task models.Task, to string) (int, string) {
+	workflow := project.EffectiveWorkflow()
+	if !workflow.RequireChildrenDone || !workflow.IsDone(to) {
+		return 0, ""
+	}
+
+	progress := models.ChecklistProgress(task.Checklist)
+	openItems := progress.Total - progress.Done
+
+	filter := openTasksFilter(project)
+	filter["parentId"] = task.ID
+	openSubtasks, err := databases.GetCollection(databases.Client, "tasks").CountDocuments(ctx, filter)
+	if err != nil {
+		return http.StatusInternalServerError, "Database error"
+	}
+
+	if openItems == 0 && openSubtasks == 0 {
+		return 0, ""
+	}
+	return http.StatusConflict, fmt.Sprintf("Task cannot be moved to %q while it has %d open subtask(s) and %d unchecked checklist item(s)", to, openSubtasks, openItems)
+}
+
+// sendChildrenError reports a move refused by checkChildrenDone
+func sendChildrenError(w http.ResponseWriter, code int, msg string) {
+	if code == http.StatusConflict {
+		utils.SendErrorCode(w, code, utils.ErrChildrenOpen, msg)
+		return
+	}
+	utils.SendError(w, code, msg)
+}
+
+// taskDescendants returns the IDs of every subtask below taskID, at any depth
+func taskDescendants(ctx context.Context, taskID string) ([]string, error) {
+	collection := databases.GetCollection(databases.Client, "tasks")
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: taskIDFilter(taskID)}},
+		// parentId holds the parent's ID as a string, even for older ObjectId IDs
+		{{Key: "$graphLookup", Value: bson.M{
+			"from":             "tasks",
+			"startWith":        bson.M{"$toString": "$_id"},
+			"connectFromField": "_id",
+			"connectToField":   "parentId",
+			"as":               "descendants",
+		}}},
+		{{Key: "$project", Value: bson.M{"ids": "$descendants._id"}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var result []struct {
+		IDs []string `bson:"ids"`
+	}
+	if err := cursor.All(ctx, &result); err != nil || len(result) == 0 {
+		return nil, err
+	}
+	return result[0].IDs, nil
+}
+
+func ListSubtasksHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	task, project, _, ok := authorizeTask(ctx, w, r, r.PathValue("taskId"), models.ProjectRoleViewer)
+	if !ok {
+		return
+	}
+
+	// 1. Direct children only, oldest first; each carries its own progress
+	collection := databases.GetCollection(databases.Client, "tasks")
+	opts := options.Find().SetSort(bson.D{{Key: "createdat", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := collection.Find(ctx, bson.M{"parentId": task.ID}, opts)
+	if err != nil {
+		log.Printf("[%s] list subtasks: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Error fetching subtasks")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	subtasks := []models.Task{}
+	if err := cursor.All(ctx, &subtasks); err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Data format error")
+		return
+	}
+	if err := addProgress(ctx, project, subtasks); err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	utils.SendSuccess(w, "Subtasks retrieved successfully", subtasks)
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"trello-lite/models"
+	"trello-lite/query"
+	"trello-lite/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// taskQueryFields are the fields a ?q= filter expression can use
+var taskQueryFields = query.Schema{
+	"title":       {Path: "title", Kind: query.Text},
+	"description": {Path: "description", Kind: query.Text},
+	"status":      {Path: "status", Kind: query.String},
+	"column":      {Path: "columnId", Kind: query.String},
+	"parent":      {Path: "parentId", Kind: query.String},
+	"assignee":    {Path: "assignedto", Kind: query.User},
+	"label":       {Path: "labels", Kind: query.Label},
+	"priority": {Path: "priority", Kind: query.Enum, Values: []string{
+		models.PriorityLow, models.PriorityMedium, models.PriorityHigh, models.PriorityUrgent,
+	}},
+	"due":     {Path: "duedate", Kind: query.Date},
+	"created": {Path: "createdat", Kind: query.Date},
+	"updated": {Path: "updatedat", Kind: query.Date},
+}
+
+// taskScope is what task filters resolve names against: the label catalogs
+// and custom fields of the projects being listed
+type taskScope struct {
+	labels []models.Label
+	fields []models.CustomField
+}
+
+// projectScope is the taskScope of a single project
+func projectScope(project models.Project) taskScope {
+	return taskScope{labels: project.Labels, fields: project.CustomFields}
+}
+
+// addTaskFilters narrows a task query by the filter parameters shared by the
+// task list and search endpoints:
+//
+//	q=<expression>        a filter expression, see package query
+//	status=Todo,Done      any of the statuses
+//	assignee=ID,me,none   assigned to any of the users; "none" is unassigned
+//	priority=High,Urgent  any of the priorities (case-insensitive)
+//	parent=ID,none        subtasks of any of the tasks; "none" is top-level tasks
+//	label=bug,ID,none     carrying any of the labels, by name or ID; "none" is unlabelled
+//	dueAfter=2026-11-01   due on or after (a date or an RFC 3339 time)
+//	dueBefore=2026-12-01  due strictly before
+//
+// Label names are looked up in the scope's label catalogs, and its custom
+// fields can be used in q as cf.<key>.
+func addTaskFilters(r *http.Request, filter bson.M, scope taskScope, errs *utils.ValidationErrors) {
+	params := r.URL.Query()
+	env := query.Env{UserID: r.Header.Get("User-ID"), Now: time.Now(), Labels: labelIndex(scope.labels)}
+
+	if q := params.Get("q"); q != "" {
+		expr, err := query.Parse(q, customFieldSchema(taskQueryFields, scope.fields), env)
+		var qerr *query.Error
+		switch {
+		case errors.As(err, &qerr):
+			*errs = append(*errs, utils.FieldError{Field: "q", Code: utils.CodeInvalidFormat, Message: qerr.Error(), Position: qerr.Pos})
+		case err != nil:
+			errs.Add("q", utils.CodeInvalidFormat, err.Error())
+		default:
+			filter["$and"] = append(filterClauses(filter), expr)
+		}
+	}
+
+	if statuses := splitList(params.Get("status")); len(statuses) > 0 {
+		filter["status"] = bson.M{"$in": statuses}
+	}
+
+	if assignees := splitList(params.Get("assignee")); len(assignees) > 0 {
+		for i, a := range assignees {
+			switch a {
+			case "me":
+				assignees[i] = r.Header.Get("User-ID")
+			case "none":
+				assignees[i] = ""
+			}
+		}
+		filter["assignedto"] = bson.M{"$in": assignees}
+	}
+
+	if parents := splitList(params.Get("parent")); len(parents) > 0 {
+		in := bson.A{}
+		for _, p := range parents {
+			if p == "none" {
+				in = append(in, nil)
+			} else {
+				in = append(in, p)
+			}
+		}
+		filter["parentId"] = bson.M{"$in": in}
+	}
+
+	if names := splitList(params.Get("label")); len(names) > 0 {
+		ids := bson.A{}
+		for _, name := range names {
+			ids = append(ids, env.LabelIDs(name)...)
+		}
+		filter["labels"] = bson.M{"$in": ids}
+	}
+
+	if priorities := splitList(params.Get("priority")); len(priorities) > 0 {
+		for i, p := range priorities {
+			normalized, ok := models.NormalizePriority(p)
+			if !ok {
+				errs.Add("priority", utils.CodeInvalidChoice, "priority must be Low, Medium, High or Urgent")
+				break
+			}
+			priorities[i] = normalized
+		}
+		filter["priority"] = bson.M{"$in": priorities}
+	}
+
+	// Tasks without a due date store the zero time; due filters never match them
+	due := bson.M{}
+	if s := params.Get("dueAfter"); s != "" {
+		if t, ok := parseDateParam(s); ok {
+			due["$gte"] = t
+		} else {
+			errs.Add("dueAfter", utils.CodeInvalidFormat, "dueAfter must be a date (YYYY-MM-DD) or an RFC 3339 time")
+		}
+	}
+	if s := params.Get("dueBefore"); s != "" {
+		if t, ok := parseDateParam(s); ok {
+			due["$lt"] = t
+		} else {
+			errs.Add("dueBefore", utils.CodeInvalidFormat, "dueBefore must be a date (YYYY-MM-DD) or an RFC 3339 time")
+		}
+	}
+	if len(due) > 0 {
+		if _, ok := due["$gte"]; !ok {
+			due["$gt"] = time.Time{}
+		}
+		filter["duedate"] = due
+	}
+}
+
+// labelIndex maps lower-case label names to the IDs of the labels with that
+// name, for query.Env
+func labelIndex(labels []models.Label) map[string][]string {
+	index := map[string][]string{}
+	for _, l := range labels {
+		name := strings.ToLower(l.Name)
+		index[name] = append(index[name], l.ID)
+	}
+	return index
+}
+
+// filterClauses returns the $and clauses already in filter, if any
+func filterClauses(filter bson.M) bson.A {
+	if clauses, ok := filter["$and"].(bson.A); ok {
+		return clauses
+	}
+	return bson.A{}
+}
+
+// splitList reads a comma-separated query value, dropping empty entries
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// parseDateParam accepts a calendar date (midnight UTC) or an RFC 3339 time
+func parseDateParam(s string) (time.Time, bool) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, true
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	return t, err == nil
+}
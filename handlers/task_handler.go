@@ -3,7 +3,9 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"time"
 	"trello-lite/databases"
@@ -18,84 +20,231 @@ import (
 func CreateTaskHandler(w http.ResponseWriter, r *http.Request) {
 	var task models.Task
 	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
+	task.ProjectId = pathParam(r, "projectId", task.ProjectId)
 
-	task.CreatedAt = time.Now()
-	task.UpdatedAt = time.Now()
-	if task.Status == "" {
-		task.Status = "Todo"
-	}
-
-	collection := databases.GetCollection(databases.Client, "tasks")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	result, err := collection.InsertOne(ctx, task)
-	if err != nil {
+	// 1. Check the fields themselves, then everything that depends on the project
+	if task.Priority != "" {
+		if priority, ok := models.NormalizePriority(task.Priority); ok {
+			task.Priority = priority
+		}
+	}
+	errs := utils.Validate(task)
+	if !task.DueDate.IsZero() && task.DueDate.Before(time.Now().UTC().Truncate(24*time.Hour)) {
+		errs.Add("duedate", utils.CodeInvalidFormat, "duedate cannot be in the past")
+	}
 
-		fmt.Println("DB Insert Error:", err)
-		http.Error(w, "Database error", http.StatusInternalServerError)
+	// Only members of the project can add tasks to it; a project the caller
+	// cannot see is reported as a bad projectid
+	project, _, code, msg := loadProjectForCaller(ctx, r, task.ProjectId, models.ProjectRoleMember)
+	switch {
+	case code == http.StatusNotFound:
+		errs.Add("projectid", utils.CodeNotFound, "projectid does not refer to a project you belong to")
+	case code == http.StatusBadRequest:
+		// Already reported by the required rule
+	case code != 0:
+		utils.SendError(w, code, msg)
+		return
+	case project.Archived:
+		utils.SendErrorCode(w, http.StatusConflict, utils.ErrProjectArchived, "Project is archived and read-only")
+		return
+	default:
+		if task.AssignedTo != "" && project.RoleOf(task.AssignedTo) == "" {
+			errs.Add("assignedto", utils.CodeNotAllowed, "assignedto must be a member of the project")
+		}
+
+		// New tasks start in the workflow's first status unless told otherwise
+		workflow := project.EffectiveWorkflow()
+		if task.Status == "" {
+			task.Status = workflow.InitialStatus()
+		}
+		if !errs.Has("status") && !workflow.HasStatus(task.Status) {
+			errs.Add("status", utils.CodeInvalidChoice, "Unknown status \""+task.Status+"\" for this project")
+		}
+
+		// A subtask lives in its parent's project
+		if task.ParentID != "" {
+			parentFilter := taskIDFilter(task.ParentID)
+			parentFilter["projectid"] = project.ID
+			var parent models.Task
+			if err := databases.GetCollection(databases.Client, "tasks").FindOne(ctx, parentFilter).Decode(&parent); err != nil {
+				errs.Add("parentId", utils.CodeNotFound, "parentId is not a task in this project")
+			}
+			task.ParentID = parent.ID
+		}
+		for i, item := range task.Checklist {
+			if item.AssignedTo != "" && project.RoleOf(item.AssignedTo) == "" {
+				errs.Add(fmt.Sprintf("checklist[%d].assignedTo", i), utils.CodeNotAllowed, "assignedTo must be a member of the project")
+			}
+		}
+		task.Labels = checkTaskLabels(project, task.Labels, &errs)
+		task.CustomFields = applyCustomFields(project, nil, task.CustomFields, &errs)
+	}
+	if len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "Task created",
-		"id":      result.InsertedID,
-	})
-}
+	task.ID = primitive.NewObjectID().Hex()
+	for i := range task.Checklist {
+		task.Checklist[i].ID = primitive.NewObjectID().Hex()
+	}
+	task.Progress = nil
+	task.Version = 1
+	task.CreatedAt = time.Now()
+	task.UpdatedAt = time.Now()
 
-func GetTasksByProjectHandler(w http.ResponseWriter, r *http.Request) {
-	projectID := r.URL.Query().Get("projectId")
-	role := r.Header.Get("Role")
-	userID := r.Header.Get("User-ID")
+	// 2. Place the task at the bottom of its board column
+	column, hasColumn := project.Column(task.ColumnID)
+	if !hasColumn {
+		column, hasColumn = columnForStatus(project, task.Status)
+	}
+	if hasColumn {
+		task.ColumnID = column.ID
+		if column.Status != "" {
+			task.Status = column.Status
+		}
+		rank, err := rankAtEnd(ctx, project.ID, column.ID)
+		if err != nil {
+			utils.SendError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		task.Rank = rank
+	} else {
+		task.ColumnID = ""
+	}
 
-	if projectID == "" {
-		utils.SendError(w, http.StatusBadRequest, "Missing projectId")
+	// 3. A task created straight into a limited status counts like a move into it
+	warnings, err := insertTaskWithWIP(ctx, project, task)
+	if err != nil && !isWIPExceeded(err) {
+		log.Printf("[%s] insert task: %v", r.Header.Get(utils.RequestIDHeader), err)
+	}
+	if sendWIPError(w, err) {
 		return
 	}
 
+	setTaskETag(w, task.Version)
+	utils.SendCreated(w, "Task created", map[string]interface{}{
+		"id":       task.ID,
+		"version":  task.Version,
+		"warnings": warnings,
+	})
+}
+
+func GetTasksByProjectHandler(w http.ResponseWriter, r *http.Request) {
+	projectID := pathParam(r, "projectId", r.URL.Query().Get("projectId"))
+
 	collection := databases.GetCollection(databases.Client, "tasks")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// 1. Build the Match Criteria based on RBAC
-	matchCriteria := bson.M{"projectid": projectID}
+	// 1. Every project role, including viewer, can read the project's tasks
+	project, _, ok := authorizeProject(ctx, w, r, projectID, models.ProjectRoleViewer)
+	if !ok {
+		return
+	}
 
-	if role == "User" {
-		// Regular users only see tasks assigned to them
-		matchCriteria["assignedto"] = userID
-	} else if role != "Super Admin" && role != "Admin" {
-		utils.SendError(w, http.StatusUnauthorized, "Unauthorized Role")
+	// 2. Filters and paging come from the query string
+	matchCriteria := bson.M{"projectid": projectID}
+	var errs utils.ValidationErrors
+	addTaskFilters(r, matchCriteria, projectScope(project), &errs)
+	board := r.URL.Query().Get("view") == "board"
+	page := parsePageRequest(r, taskListSpec.withSorts(customFieldSorts(project.CustomFields)), &errs)
+	if len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
 		return
 	}
 
-	// 2. Define the Aggregation Pipeline
-	pipeline := mongo.Pipeline{
-		bson.D{{Key: "$match", Value: matchCriteria}},
-		bson.D{{Key: "$addFields", Value: bson.M{"id": "$_id"}}},
+	// 3. Define the Aggregation Pipeline. The board view needs every matching
+	// task, so it is not paged and always comes in board order.
+	pipeline := mongo.Pipeline{bson.D{{Key: "$match", Value: matchCriteria}}}
+	if board {
+		pipeline = append(pipeline, bson.D{{Key: "$sort", Value: bson.D{{Key: "rank", Value: 1}, {Key: "_id", Value: 1}}}})
+	} else {
+		pipeline = append(pipeline, page.stages()...)
 	}
+	pipeline = append(pipeline, bson.D{{Key: "$addFields", Value: bson.M{"id": "$_id"}}})
 
-	// 3. Execute Aggregation
+	// 4. Execute Aggregation
 	cursor, err := collection.Aggregate(ctx, pipeline)
 	if err != nil {
+		log.Printf("[%s] list tasks: %v", r.Header.Get(utils.RequestIDHeader), err)
 		utils.SendError(w, http.StatusInternalServerError, "Error fetching tasks")
 		return
 	}
 	defer cursor.Close(ctx)
 
-	// 4. Initialize as empty slice to avoid 'null' in JSON
-	tasks := []models.Task{}
-	if err := cursor.All(ctx, &tasks); err != nil {
-		fmt.Println("MONGODB DECODE ERROR:", err)
+	// 5. ?view=board groups the tasks into the project's columns
+	if board {
+		tasks := []models.Task{}
+		if err := cursor.All(ctx, &tasks); err != nil {
+			log.Printf("[%s] decode tasks: %v", r.Header.Get(utils.RequestIDHeader), err)
+			utils.SendError(w, http.StatusInternalServerError, "Data format error")
+			return
+		}
+		if err := addProgress(ctx, project, tasks); err != nil {
+			utils.SendError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		utils.SendSuccess(w, "Board retrieved successfully", groupTasksByColumn(project, tasks))
+		return
+	}
+
+	// 6. Otherwise send one page, with the cursor for the next
+	var tasks []models.Task
+	info, err := readPage(ctx, cursor, page, &tasks)
+	if err != nil {
+		log.Printf("[%s] decode tasks: %v", r.Header.Get(utils.RequestIDHeader), err)
 		utils.SendError(w, http.StatusInternalServerError, "Data format error")
 		return
 	}
+	if err := addProgress(ctx, project, tasks); err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	utils.SendPage(w, "Tasks retrieved successfully", tasks, info)
+}
+
+func GetTaskHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	task, project, _, ok := authorizeTask(ctx, w, r, pathParam(r, "taskId", r.URL.Query().Get("id")), models.ProjectRoleViewer)
+	if !ok {
+		return
+	}
+
+	// The ETag is what clients send back in If-Match when they update the task.
+	// ?include=dependencies adds the task's links, which are not part of its
+	// version, so that response is never a 304.
+	withDependencies := r.URL.Query().Get("include") == "dependencies"
+	setTaskETag(w, task.Version)
+	if !withDependencies && r.Header.Get("If-None-Match") == taskETag(task.Version) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	tasks := []models.Task{task}
+	if err := addProgress(ctx, project, tasks); err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if withDependencies {
+		deps, err := loadDependencies(ctx, r, task)
+		if err != nil {
+			log.Printf("[%s] load dependencies: %v", r.Header.Get(utils.RequestIDHeader), err)
+			utils.SendError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		tasks[0].Dependencies = deps
+	}
 
-	// 5. Send Success Response using your Utils
-	utils.SendSuccess(w, "Tasks retrieved successfully", tasks)
+	utils.SendSuccess(w, "Task retrieved successfully", tasks[0])
 }
 
 func UpdateTaskStatusHandler(w http.ResponseWriter, r *http.Request) {
@@ -104,92 +253,157 @@ func UpdateTaskStatusHandler(w http.ResponseWriter, r *http.Request) {
 		Status string `json:"status"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
+	data.ID = pathParam(r, "taskId", data.ID)
 
-	role := r.Header.Get("Role")
 	userID := r.Header.Get("User-ID")
-	collection := databases.GetCollection(databases.Client, "tasks")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// 1. Try to find the task by String ID (like "0001")
-	filter := bson.M{"_id": data.ID}
+	if data.Status == "" {
+		var errs utils.ValidationErrors
+		errs.Add("status", utils.CodeRequired, "status is required")
+		utils.SendValidationErrors(w, errs)
+		return
+	}
 
-	// 2. Security for regular Users
-	if role == "User" {
-		filter["assignedto"] = userID
+	// 1. Members can move tasks; viewers cannot
+	task, project, role, ok := authorizeTask(ctx, w, r, data.ID, models.ProjectRoleMember)
+	if !ok {
+		return
 	}
 
-	update := bson.M{"$set": bson.M{
+	// 2. Plain members may only update tasks assigned to them
+	if role == models.ProjectRoleMember && task.AssignedTo != userID {
+		utils.SendError(w, http.StatusForbidden, "Members can only update tasks assigned to them")
+		return
+	}
+	if !checkIfMatch(w, r, task) {
+		return
+	}
+
+	// 3. The project's workflow decides which moves are legal and who may make them
+	if !project.EffectiveWorkflow().HasStatus(data.Status) {
+		var errs utils.ValidationErrors
+		errs.Add("status", utils.CodeInvalidChoice, "Unknown status \""+data.Status+"\" for this project")
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+	if code, msg := checkTransition(project, role, task.Status, data.Status); code != 0 {
+		sendTransitionError(w, code, msg)
+		return
+	}
+	if code, msg := checkChildrenDone(ctx, project, task, data.Status); code != 0 {
+		sendChildrenError(w, code, msg)
+		return
+	}
+	blocked, code, msg := checkBlockers(ctx, project, task, data.Status)
+	if code != 0 {
+		sendBlockersError(w, code, msg)
+		return
+	}
+
+	set := bson.M{
 		"status":    data.Status,
 		"updatedat": time.Now(),
-	}}
-
-	result, err := collection.UpdateOne(ctx, filter, update)
+	}
 
-	// 3. FALLBACK: If string ID fails, try converting to Hex ObjectId
-	if err == nil && result.MatchedCount == 0 && len(data.ID) == 24 {
-		objID, _ := primitive.ObjectIDFromHex(data.ID)
-		filter = bson.M{"_id": objID}
-		if role == "User" {
-			filter["assignedto"] = userID
-		}
-		result, err = collection.UpdateOne(ctx, filter, update)
+	// 4. Keep the board in step with the new status
+	if err := syncColumnForStatus(ctx, project, task, data.Status, set); err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
 	}
 
-	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
+	// 5. Write the change, subject to the project's WIP limits, only if the
+	// task is still at the version everything above was checked against
+	warnings, result, err := updateTaskWithWIP(ctx, project, task, data.Status, task.AssignedTo, taskVersionFilter(task), bumpVersion(bson.M{"$set": set}))
+	if sendWIPError(w, err) {
 		return
 	}
 
 	if result.MatchedCount == 0 {
-		http.Error(w, "Task not found. Check if ID "+data.ID+" exists in the 'tasks' collection.", http.StatusNotFound)
+		sendVersionConflict(w)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"message": "Task updated successfully"})
+	setTaskETag(w, task.Version+1)
+	warnings = append(warnings, blocked...)
+	utils.SendSuccess(w, "Task updated successfully", map[string]interface{}{"version": task.Version + 1, "warnings": warnings})
 }
 
 func DeleteTaskHandler(w http.ResponseWriter, r *http.Request) {
-	taskID := r.URL.Query().Get("id")
-	role := r.Header.Get("Role")
+	taskID := pathParam(r, "taskId", r.URL.Query().Get("id"))
 
 	collection := databases.GetCollection(databases.Client, "tasks")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	// DIAGNOSTIC: Count how many tasks exist in total
-	count, _ := collection.CountDocuments(context.TODO(), bson.M{})
-	fmt.Printf("DEBUG: Total tasks in collection: %d | Trying to delete: '%s'\n", count, taskID)
-
-	if role == "User" {
-		http.Error(w, "Unauthorized", http.StatusForbidden)
+	// Deleting is reserved for maintainers and owners of the task's project
+	task, _, _, ok := authorizeTask(ctx, w, r, taskID, models.ProjectRoleMaintainer)
+	if !ok || !checkIfMatch(w, r, task) {
 		return
 	}
 
-	// Attempt the delete
-	result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": taskID})
+	// Attempt the delete; with If-Match, only the version the client saw may be deleted.
+	// The task's subtasks, at any depth, and all their comments and links go
+	// with it, or nothing is deleted at all.
+	filter := taskIDFilter(task.ID)
+	if r.Header.Get("If-Match") != "" {
+		filter = taskVersionFilter(task)
+	}
+	err := databases.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		// Subtasks are found through the task, so look them up before it goes
+		descendants, err := taskDescendants(sc, task.ID)
+		if err != nil {
+			return err
+		}
+		result, err := collection.DeleteOne(sc, filter)
+		if err != nil {
+			return err
+		}
+		if result.DeletedCount == 0 {
+			return mongo.ErrNoDocuments
+		}
 
-	if err != nil {
-		fmt.Println("DB ERROR:", err)
-		http.Error(w, "Delete failed", http.StatusInternalServerError)
+		removed := append([]string{task.ID}, descendants...)
+		if len(descendants) > 0 {
+			if _, err := collection.DeleteMany(sc, bson.M{"_id": bson.M{"$in": descendants}}); err != nil {
+				return err
+			}
+		}
+		if _, err := databases.GetCollection(databases.Client, "comments").DeleteMany(sc, bson.M{"taskId": bson.M{"$in": removed}}); err != nil {
+			return err
+		}
+		links := bson.M{"$or": []bson.M{{"fromTaskId": bson.M{"$in": removed}}, {"toTaskId": bson.M{"$in": removed}}}}
+		_, err = databases.GetCollection(databases.Client, "task_links").DeleteMany(sc, links)
+		return err
+	})
+
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Changed since the If-Match version was read, or already deleted
+		if r.Header.Get("If-Match") != "" {
+			sendVersionConflict(w)
+			return
+		}
+		utils.SendError(w, http.StatusNotFound, "Task not found")
 		return
 	}
-
-	if result.DeletedCount == 0 {
-		fmt.Println("LOG: No document matched this ID.")
-		http.Error(w, "Not Found", http.StatusNotFound)
+	if err != nil {
+		log.Printf("[%s] delete task: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Delete failed")
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]string{"message": "Deleted " + taskID})
+	utils.SendSuccess(w, "Deleted "+taskID, nil)
 }
 func SearchTaskHandler(w http.ResponseWriter, r *http.Request) {
-	// 1. Get the 'title' from the URL query: /task/search?title=Fix
+	// 1. Get the 'title' from the URL query: /task/search?title=Fix, or a
+	// filter expression: /task/search?q=assignee:me AND due<7d
 	queryTitle := r.URL.Query().Get("title")
-	if queryTitle == "" {
-		http.Error(w, "Query parameter 'title' is required", http.StatusBadRequest)
+	if queryTitle == "" && r.URL.Query().Get("q") == "" {
+		utils.SendError(w, http.StatusBadRequest, "Query parameter 'title' or 'q' is required")
 		return
 	}
 
@@ -199,27 +413,61 @@ func SearchTaskHandler(w http.ResponseWriter, r *http.Request) {
 
 	// 2. Create a Regex filter
 	// "i" means case-insensitive (finds 'fix' or 'Fix')
-	filter := bson.M{
-		"title": bson.M{"$regex": queryTitle, "$options": "i"},
+	filter := bson.M{}
+	if queryTitle != "" {
+		filter["title"] = bson.M{"$regex": queryTitle, "$options": "i"}
+	}
+
+	// 3. Only search inside projects the caller belongs to
+	projectIDs, all, err := visibleProjectIDs(ctx, r)
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Search failed")
+		return
+	}
+	if !all {
+		filter["projectid"] = bson.M{"$in": projectIDs}
+	}
+
+	// 4. The list filters and paging apply to search results too. Label names
+	// may match labels of any of the searched projects. Custom fields belong to
+	// one project each, so only the project task list filters on them.
+	var labels []models.Label
+	if r.URL.Query().Get("label") != "" || r.URL.Query().Get("q") != "" {
+		if labels, err = projectLabels(ctx, projectIDs, all); err != nil {
+			utils.SendError(w, http.StatusInternalServerError, "Search failed")
+			return
+		}
+	}
+	var errs utils.ValidationErrors
+	addTaskFilters(r, filter, taskScope{labels: labels}, &errs)
+	page := parsePageRequest(r, taskSearchSpec, &errs)
+	if len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
 	}
 
-	cursor, err := collection.Find(ctx, filter)
+	pipeline := mongo.Pipeline{bson.D{{Key: "$match", Value: filter}}}
+	pipeline = append(pipeline, page.stages()...)
+	pipeline = append(pipeline, bson.D{{Key: "$addFields", Value: bson.M{"id": "$_id"}}})
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
 	if err != nil {
-		http.Error(w, "Search failed", http.StatusInternalServerError)
+		log.Printf("[%s] search tasks: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Search failed")
 		return
 	}
 	defer cursor.Close(ctx)
 
-	// 3. Decode the results into a slice (list) of Tasks
+	// 5. Decode one page of results
 	var results []models.Task
-	if err = cursor.All(ctx, &results); err != nil {
-		http.Error(w, "Error parsing results", http.StatusInternalServerError)
+	info, err := readPage(ctx, cursor, page, &results)
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Error parsing results")
 		return
 	}
 
-	// 4. Send back the results
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(results)
+	// 6. Send back the results
+	utils.SendPage(w, "Tasks retrieved successfully", results, info)
 }
 
 func UpdateTaskownerHandler(w http.ResponseWriter, r *http.Request) {
@@ -228,51 +476,48 @@ func UpdateTaskownerHandler(w http.ResponseWriter, r *http.Request) {
 		AssignedTo string `json:"assignedto"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
+	data.ID = pathParam(r, "taskId", data.ID)
 
-	role := r.Header.Get("Role")
-	userID := r.Header.Get("User-ID")
-	collection := databases.GetCollection(databases.Client, "tasks")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// 1. Try to find the task by String ID (like "0001")
-	filter := bson.M{"_id": data.ID}
+	// 1. Reassigning work is a maintainer decision
+	task, project, _, ok := authorizeTask(ctx, w, r, data.ID, models.ProjectRoleMaintainer)
+	if !ok {
+		return
+	}
+
+	if !checkIfMatch(w, r, task) {
+		return
+	}
 
-	// 2. Security for regular Users
-	if role == "User" {
-		filter["assignedto"] = userID
+	// 2. Tasks can only be handed to people in the project (or unassigned)
+	if data.AssignedTo != "" && project.RoleOf(data.AssignedTo) == "" {
+		var errs utils.ValidationErrors
+		errs.Add("assignedto", utils.CodeNotAllowed, "assignedto must be a member of the project")
+		utils.SendValidationErrors(w, errs)
+		return
 	}
 
 	update := bson.M{"$set": bson.M{
-		"AssignedTo": data.AssignedTo,
+		"assignedto": data.AssignedTo,
 		"updatedat":  time.Now(),
 	}}
 
-	result, err := collection.UpdateOne(ctx, filter, update)
-
-	// 3. FALLBACK: If string ID fails, try converting to Hex ObjectId
-	if err == nil && result.MatchedCount == 0 && len(data.ID) == 24 {
-		objID, _ := primitive.ObjectIDFromHex(data.ID)
-		filter = bson.M{"_id": objID}
-		if role == "User" {
-			filter["assignedto"] = userID
-		}
-		result, err = collection.UpdateOne(ctx, filter, update)
-	}
-
-	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
+	// 3. Per-assignee WIP limits apply to the new assignee
+	warnings, result, err := updateTaskWithWIP(ctx, project, task, task.Status, data.AssignedTo, taskVersionFilter(task), bumpVersion(update))
+	if sendWIPError(w, err) {
 		return
 	}
 
 	if result.MatchedCount == 0 {
-		http.Error(w, "Task not found. Check if ID "+data.ID+" exists in the 'tasks' collection.", http.StatusNotFound)
+		sendVersionConflict(w)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"message": "Task updated successfully"})
+	setTaskETag(w, task.Version+1)
+	utils.SendSuccess(w, "Task updated successfully", map[string]interface{}{"version": task.Version + 1, "warnings": warnings})
 }
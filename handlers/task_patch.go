@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"trello-lite/models"
+	"trello-lite/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// taskPatchFields lists the fields PATCH /task may change and the project
+// role needed for each. Members are further limited to their own tasks.
+var taskPatchFields = map[string]string{
+	"title":        models.ProjectRoleMember,
+	"description":  models.ProjectRoleMember,
+	"status":       models.ProjectRoleMember,
+	"priority":     models.ProjectRoleMember,
+	"duedate":      models.ProjectRoleMember,
+	"assignedto":   models.ProjectRoleMaintainer,
+	"labels":       models.ProjectRoleMember,
+	"customFields": models.ProjectRoleMember,
+}
+
+// applyTaskPatch decodes one merge-patch member and applies it to task,
+// returning an error code and message when the value is unusable.
+// A JSON null clears the field where that makes sense.
+func applyTaskPatch(task *models.Task, field string, raw json.RawMessage) (string, string) {
+	isNull := bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+
+	switch field {
+	case "title":
+		var title string
+		if isNull || json.Unmarshal(raw, &title) != nil || strings.TrimSpace(title) == "" {
+			return utils.CodeRequired, "title must be a non-empty string"
+		}
+		task.Title = strings.TrimSpace(title)
+
+	case "description":
+		var description string
+		if !isNull && json.Unmarshal(raw, &description) != nil {
+			return utils.CodeInvalidFormat, "description must be a string"
+		}
+		task.Description = description
+
+	case "status":
+		var status string
+		if isNull || json.Unmarshal(raw, &status) != nil || status == "" {
+			return utils.CodeRequired, "status must be a non-empty string"
+		}
+		task.Status = status
+
+	case "priority":
+		var priority string
+		if !isNull && json.Unmarshal(raw, &priority) != nil {
+			return utils.CodeInvalidFormat, "priority must be a string"
+		}
+		if canonical, ok := models.NormalizePriority(priority); ok {
+			priority = canonical
+		}
+		task.Priority = priority
+
+	case "duedate":
+		var due time.Time
+		if !isNull && json.Unmarshal(raw, &due) != nil {
+			return utils.CodeInvalidFormat, "duedate must be an RFC 3339 timestamp"
+		}
+		// Compare by day so a task can be due the day it was created
+		created := task.CreatedAt.UTC().Truncate(24 * time.Hour)
+		if !due.IsZero() && !task.CreatedAt.IsZero() && due.Before(created) {
+			return utils.CodeInvalidFormat, "duedate cannot be before the task was created"
+		}
+		task.DueDate = due
+
+	case "assignedto":
+		var assignee string
+		if !isNull && json.Unmarshal(raw, &assignee) != nil {
+			return utils.CodeInvalidFormat, "assignedto must be a string"
+		}
+		task.AssignedTo = assignee
+
+	case "labels":
+		var labels []string
+		if !isNull && json.Unmarshal(raw, &labels) != nil {
+			return utils.CodeInvalidFormat, "labels must be an array of label IDs"
+		}
+		task.Labels = labels
+
+	case "customFields":
+		// Values are merged into the task's own by the handler, which knows
+		// the project's fields; null clears them all
+		var values map[string]interface{}
+		if !isNull && json.Unmarshal(raw, &values) != nil {
+			return utils.CodeInvalidFormat, "customFields must be an object of field keys to values"
+		}
+		if isNull {
+			task.CustomFields = nil
+		}
+	}
+	return "", ""
+}
+
+// PatchTaskHandler applies a JSON Merge Patch (RFC 7386) to a task:
+// PATCH /task?id=... with {"priority": "High", "duedate": null}.
+func PatchTaskHandler(w http.ResponseWriter, r *http.Request) {
+	var patch map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil || len(patch) == 0 {
+		utils.SendError(w, http.StatusBadRequest, "Request body must be a non-empty JSON object")
+		return
+	}
+
+	userID := r.Header.Get("User-ID")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 1. Nothing can be patched below member
+	task, project, role, ok := authorizeTask(ctx, w, r, pathParam(r, "taskId", r.URL.Query().Get("id")), models.ProjectRoleMember)
+	if !ok || !checkIfMatch(w, r, task) {
+		return
+	}
+
+	// 2. Check every field is patchable and the caller may change it
+	fields := make([]string, 0, len(patch))
+	for field := range patch {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var errs utils.ValidationErrors
+	for _, field := range fields {
+		minRole, known := taskPatchFields[field]
+		if !known {
+			errs.Add(field, utils.CodeNotAllowed, field+" cannot be changed with PATCH")
+			continue
+		}
+		if !models.ProjectRoleAtLeast(role, minRole) {
+			utils.SendError(w, http.StatusForbidden, "Changing "+field+" requires project role "+minRole)
+			return
+		}
+	}
+	if role == models.ProjectRoleMember && task.AssignedTo != userID {
+		utils.SendError(w, http.StatusForbidden, "Members can only update tasks assigned to them")
+		return
+	}
+
+	// 3. Apply each field to a copy of the task, then validate the result.
+	// Only the patched fields are reported, so old data cannot block a patch.
+	patched := task
+	for _, field := range fields {
+		if code, msg := applyTaskPatch(&patched, field, patch[field]); code != "" {
+			errs.Add(field, code, msg)
+		}
+	}
+	for _, e := range utils.Validate(patched) {
+		if _, sent := patch[e.Field]; sent && !errs.Has(e.Field) {
+			errs = append(errs, e)
+		}
+	}
+	if _, sent := patch["status"]; sent && !errs.Has("status") && !project.EffectiveWorkflow().HasStatus(patched.Status) {
+		errs.Add("status", utils.CodeInvalidChoice, "Unknown status \""+patched.Status+"\" for this project")
+	}
+	if patched.AssignedTo != task.AssignedTo && patched.AssignedTo != "" && project.RoleOf(patched.AssignedTo) == "" {
+		errs.Add("assignedto", utils.CodeNotAllowed, "assignedto must be a member of the project")
+	}
+	if _, sent := patch["labels"]; sent {
+		patched.Labels = checkTaskLabels(project, patched.Labels, &errs)
+	}
+	var values map[string]interface{}
+	if raw, sent := patch["customFields"]; sent && !errs.Has("customFields") && json.Unmarshal(raw, &values) == nil && values != nil {
+		patched.CustomFields = applyCustomFields(project, task.CustomFields, values, &errs)
+	}
+	if len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	set := bson.M{
+		"title":       patched.Title,
+		"description": patched.Description,
+		"status":      patched.Status,
+		"priority":    patched.Priority,
+		"duedate":     patched.DueDate,
+		"assignedto":  patched.AssignedTo,
+		"labels":      patched.Labels,
+		"updatedat":   time.Now(),
+	}
+	if _, sent := patch["customFields"]; sent {
+		set["customFields"] = patched.CustomFields
+	}
+
+	// 4. Status changes follow the workflow and keep the board in step
+	var blocked []string
+	if patched.Status != task.Status {
+		if code, msg := checkTransition(project, role, task.Status, patched.Status); code != 0 {
+			sendTransitionError(w, code, msg)
+			return
+		}
+		if code, msg := checkChildrenDone(ctx, project, task, patched.Status); code != 0 {
+			sendChildrenError(w, code, msg)
+			return
+		}
+		var code int
+		var msg string
+		if blocked, code, msg = checkBlockers(ctx, project, task, patched.Status); code != 0 {
+			sendBlockersError(w, code, msg)
+			return
+		}
+		if err := syncColumnForStatus(ctx, project, task, patched.Status, set); err != nil {
+			utils.SendError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+	}
+
+	// 5. One versioned write, subject to the WIP limits of the new status and assignee
+	warnings, result, err := updateTaskWithWIP(ctx, project, task, patched.Status, patched.AssignedTo, taskVersionFilter(task), bumpVersion(bson.M{"$set": set}))
+	if sendWIPError(w, err) {
+		return
+	}
+	if result.MatchedCount == 0 {
+		sendVersionConflict(w)
+		return
+	}
+
+	patched.UpdatedAt = set["updatedat"].(time.Time)
+	if columnID, ok := set["columnId"].(string); ok {
+		patched.ColumnID = columnID
+		patched.Rank = set["rank"].(string)
+	}
+	patched.Version = task.Version + 1
+
+	setTaskETag(w, patched.Version)
+	warnings = append(warnings, blocked...)
+	utils.SendSuccess(w, "Task updated successfully", map[string]interface{}{"task": patched, "warnings": warnings})
+}
@@ -3,42 +3,83 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 	"trello-lite/databases"
 	"trello-lite/models"
 	"trello-lite/utils"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func SignupHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	// Only these fields are accepted; role and ID are always decided by the server
+	var request struct {
+		Name        string `json:"name" validate:"required,max=100"`
+		Email       string `json:"email" validate:"required,email,max=254"`
+		Password    string `json:"password"`
+		InviteToken string `json:"inviteToken"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
+	request.Email = strings.TrimSpace(request.Email)
 
-	var newUser models.User
-	if err := json.NewDecoder(r.Body).Decode(&newUser); err != nil {
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+	errs := utils.Validate(request)
+	utils.ValidatePasswordField(&errs, "password", request.Password)
+	if len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
 		return
 	}
 
-	newUser.CreatedAt = time.Now()
+	hash, err := utils.HashPassword(request.Password)
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Error securing password")
+		return
+	}
+
+	// Self-signup always produces a plain User
+	newUser := models.User{
+		ID:           primitive.NewObjectID().Hex(),
+		Name:         request.Name,
+		Email:        request.Email,
+		Role:         models.RoleUser,
+		PasswordHash: hash,
+		CreatedAt:    time.Now(),
+	}
 	collection := databases.GetCollection(databases.Client, "users")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	_, err := collection.InsertOne(ctx, newUser)
+	_, err = collection.InsertOne(ctx, newUser)
+	if mongo.IsDuplicateKeyError(err) {
+		utils.SendError(w, http.StatusConflict, "Email is already registered")
+		return
+	}
 	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Signup successful!"})
+	response := map[string]string{"id": newUser.ID}
+
+	// Signing up from an invitation link joins the project straight away
+	if request.InviteToken != "" {
+		if invitation, err := redeemInvitation(ctx, request.InviteToken, newUser); err != nil {
+			response["invitationError"] = err.Error()
+		} else {
+			response["projectId"] = invitation.ProjectID
+		}
+	}
+
+	utils.SendCreated(w, "Signup successful!", response)
 }
 
 func GetAllUsersHandler(w http.ResponseWriter, r *http.Request) {
@@ -54,14 +95,28 @@ func GetAllUsersHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 3. Define the Aggregation Pipeline
-	// We use bson.D to avoid the "missing type in composite literal" error
-	pipeline := mongo.Pipeline{
-		bson.D{{Key: "$match", Value: bson.M{}}},                 // Match all users
-		bson.D{{Key: "$addFields", Value: bson.M{"id": "$_id"}}}, // Map _id to id
+	// 3. Optional ?role= filter, then the page to return
+	match := bson.M{}
+	var errs utils.ValidationErrors
+	if role := r.URL.Query().Get("role"); role != "" {
+		if !models.IsValidRole(role) {
+			errs.Add("role", utils.CodeInvalidChoice, "role must be User, Admin or Super Admin")
+		}
+		match["role"] = role
+	}
+	page := parsePageRequest(r, userListSpec, &errs)
+	if len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
 	}
 
-	// 4. Execute Aggregate
+	// 4. Define the Aggregation Pipeline
+	// We use bson.D to avoid the "missing type in composite literal" error
+	pipeline := mongo.Pipeline{bson.D{{Key: "$match", Value: match}}}
+	pipeline = append(pipeline, page.stages()...)
+	pipeline = append(pipeline, bson.D{{Key: "$addFields", Value: bson.M{"id": "$_id"}}}) // Map _id to id
+
+	// 5. Execute Aggregate
 	cursor, err := collection.Aggregate(ctx, pipeline)
 	if err != nil {
 		utils.SendError(w, http.StatusInternalServerError, "Failed to query users")
@@ -69,50 +124,180 @@ func GetAllUsersHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer cursor.Close(ctx)
 
-	// 5. Decode results into a slice (initialized to avoid 'null')
-	users := []models.User{}
-	if err := cursor.All(ctx, &users); err != nil {
+	// 6. Decode one page of results
+	var users []models.User
+	info, err := readPage(ctx, cursor, page, &users)
+	if err != nil {
 		utils.SendError(w, http.StatusInternalServerError, "Error parsing user data")
 		return
 	}
 
-	// 6. Send success response using Utils
-	utils.SendSuccess(w, "User list retrieved successfully", users)
+	// 7. Send success response using Utils
+	utils.SendPage(w, "User list retrieved successfully", users, info)
 }
 
 func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	var request struct {
-		Email string `json:"email"`
+		Email       string `json:"email"`
+		Password    string `json:"password"`
+		InviteToken string `json:"inviteToken"`
 	}
 
-	// 1. Decode the email from the request
+	// 1. Decode the credentials from the request
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
+	// Emails are stored trimmed at signup
+	request.Email = strings.TrimSpace(request.Email)
 
 	// 2. Look for the user in MongoDB
 	collection := databases.GetCollection(databases.Client, "users")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var user models.User
+	err := collection.FindOne(ctx, bson.M{"email": request.Email}).Decode(&user)
+
+	// 3. Verify the password. Unknown emails still pay for a hash comparison
+	// and get the same message, so the response does not leak which emails exist.
+	// Service accounts have no password and can only authenticate with API keys.
+	if !utils.CheckPassword(user.PasswordHash, request.Password) || err != nil || user.Type == models.UserTypeService {
+		utils.SendError(w, http.StatusUnauthorized, "Invalid email or password")
+		return
+	}
+
+	// 4. Return an access token, a refresh token and the role to the user
+	tokens, err := issueTokens(ctx, user, "")
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Error generating token")
+		return
+	}
+
+	// Existing users can redeem an invitation while logging in
+	if request.InviteToken != "" {
+		if invitation, err := redeemInvitation(ctx, request.InviteToken, user); err != nil {
+			tokens["invitationError"] = err.Error()
+		} else {
+			tokens["projectId"] = invitation.ProjectID
+		}
+	}
+
+	utils.SendSuccess(w, "Authentication successful", tokens)
+}
+
+func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		OldPassword string `json:"oldPassword" validate:"required"`
+		NewPassword string `json:"newPassword"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	errs := utils.Validate(request)
+	utils.ValidatePasswordField(&errs, "newPassword", request.NewPassword)
+	if len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	userID := r.Header.Get("User-ID")
+	collection := databases.GetCollection(databases.Client, "users")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 1. Re-verify the current password before allowing the change
 	var user models.User
-	err := collection.FindOne(context.TODO(), bson.M{"email": request.Email}).Decode(&user)
+	err := collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
+	if !utils.CheckPassword(user.PasswordHash, request.OldPassword) || err != nil {
+		utils.SendError(w, http.StatusUnauthorized, "Current password is incorrect")
+		return
+	}
 
+	// 2. Store the new hash
+	hash, err := utils.HashPassword(request.NewPassword)
 	if err != nil {
-		utils.SendError(w, http.StatusUnauthorized, "User not found. Please signup first.")
+		utils.SendError(w, http.StatusInternalServerError, "Error securing password")
+		return
+	}
+
+	// 3. Bumping the version ends every other session's access tokens, and
+	// their refresh tokens are revoked, so a leaked password stops working
+	update := bson.M{"$set": bson.M{"passwordHash": hash}, "$inc": bson.M{"tokenVersion": 1}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := collection.FindOneAndUpdate(ctx, bson.M{"_id": userID}, update, opts).Decode(&user); err != nil {
+		log.Printf("[%s] change password: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if err := revokeRefreshTokens(ctx, bson.M{"userId": userID}); err != nil {
+		log.Printf("[%s] revoke refresh tokens: %v", r.Header.Get(utils.RequestIDHeader), err)
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
 		return
 	}
 
-	// 3. Instead of checking password, we use the Role found in the DB
-	// This makes the Role the "Key" for the token
-	token, err := utils.GenerateJWT(user.ID, user.Role)
+	// 4. The current session carries on with fresh tokens
+	tokens, err := issueTokens(ctx, user, "")
 	if err != nil {
 		utils.SendError(w, http.StatusInternalServerError, "Error generating token")
 		return
 	}
 
-	// 4. Return the token and the role to the user
-	utils.SendSuccess(w, "Authentication successful", map[string]string{
-		"token": token,
-		"role":  user.Role,
-		"id":    user.ID,
+	utils.SendSuccess(w, "Password changed successfully", tokens)
+}
+
+func UpdateUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Only a Super Admin can promote or demote users
+	if r.Header.Get("Role") != models.RoleSuperAdmin {
+		utils.SendError(w, http.StatusForbidden, "Access denied: Super Admin privileges required")
+		return
+	}
+
+	var request struct {
+		ID   string `json:"id" validate:"required"`
+		Role string `json:"role" validate:"required,oneof=User|Admin|Super Admin"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	request.ID = pathParam(r, "userId", request.ID)
+
+	if errs := utils.Validate(request); len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	// Prevents the last Super Admin from locking everyone out by demoting themselves
+	if request.ID == r.Header.Get("User-ID") {
+		utils.SendError(w, http.StatusBadRequest, "You cannot change your own role")
+		return
+	}
+
+	collection := databases.GetCollection(databases.Client, "users")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 2. Bumping the token version logs the user out everywhere
+	update := bson.M{
+		"$set": bson.M{"role": request.Role},
+		"$inc": bson.M{"tokenVersion": 1},
+	}
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": request.ID}, update)
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if result.MatchedCount == 0 {
+		utils.SendError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	utils.SendSuccess(w, "User role updated", map[string]string{
+		"id":   request.ID,
+		"role": request.Role,
 	})
 }
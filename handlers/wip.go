@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"trello-lite/databases"
+	"trello-lite/models"
+	"trello-lite/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// errWIPExceeded aborts a transaction when a reject-mode limit would be exceeded
+type errWIPExceeded struct {
+	limit models.WIPLimit
+}
+
+func (e errWIPExceeded) Error() string {
+	if e.limit.PerAssignee {
+		return fmt.Sprintf("WIP limit of %d per assignee reached for status %q", e.limit.Limit, e.limit.Status)
+	}
+	return fmt.Sprintf("WIP limit of %d reached for status %q", e.limit.Limit, e.limit.Status)
+}
+
+// applicableWIPLimits returns the limits a task would newly count against if
+// it ended up in status with assignee
+func applicableWIPLimits(project models.Project, task models.Task, status, assignee string) []models.WIPLimit {
+	limits := []models.WIPLimit{}
+	for _, l := range project.WIPLimits {
+		if l.Status != status {
+			continue
+		}
+		if l.PerAssignee {
+			// Unassigned tasks count against nobody's personal limit
+			if assignee == "" || (task.Status == status && task.AssignedTo == assignee) {
+				continue
+			}
+		} else if task.Status == status {
+			continue
+		}
+		limits = append(limits, l)
+	}
+	return limits
+}
+
+// updateTaskWithWIP writes a task change that moves it into status/assignee,
+// enforcing the project's WIP limits.
+//
+// The check and the write share a transaction that first bumps a lock
+// document per limit. Two concurrent moves into the same status both write
+// that document, so MongoDB aborts one of them with a write conflict; the
+// retry then counts the other move too. That makes the limit race-safe.
+func updateTaskWithWIP(ctx context.Context, project models.Project, task models.Task, status, assignee string, filter, update bson.M) ([]string, *mongo.UpdateResult, error) {
+	collection := databases.GetCollection(databases.Client, "tasks")
+	limits := applicableWIPLimits(project, task, status, assignee)
+	if len(limits) == 0 {
+		result, err := collection.UpdateOne(ctx, filter, update)
+		return nil, result, err
+	}
+
+	var warnings []string
+	var result *mongo.UpdateResult
+	err := databases.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		var err error
+		if warnings, err = checkWIPLimits(sc, project, task.ID, assignee, limits); err != nil {
+			return err
+		}
+		result, err = collection.UpdateOne(sc, filter, update)
+		return err
+	})
+	return warnings, result, err
+}
+
+// insertTaskWithWIP creates a task, enforcing the WIP limits of its status
+// and assignee the same way updateTaskWithWIP does for moves
+func insertTaskWithWIP(ctx context.Context, project models.Project, task models.Task) ([]string, error) {
+	collection := databases.GetCollection(databases.Client, "tasks")
+	limits := applicableWIPLimits(project, models.Task{}, task.Status, task.AssignedTo)
+	if len(limits) == 0 {
+		_, err := collection.InsertOne(ctx, task)
+		return nil, err
+	}
+
+	var warnings []string
+	err := databases.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		var err error
+		if warnings, err = checkWIPLimits(sc, project, task.ID, task.AssignedTo, limits); err != nil {
+			return err
+		}
+		_, err = collection.InsertOne(sc, task)
+		return err
+	})
+	return warnings, err
+}
+
+// checkWIPLimits takes the lock document of each limit and counts the tasks
+// already in it, other than taskID. It returns the warnings of warn-mode
+// limits and errWIPExceeded for the first reject-mode limit that is full.
+func checkWIPLimits(sc mongo.SessionContext, project models.Project, taskID, assignee string, limits []models.WIPLimit) ([]string, error) {
+	collection := databases.GetCollection(databases.Client, "tasks")
+	locks := databases.GetCollection(databases.Client, "wip_locks")
+
+	var warnings []string
+	for _, l := range limits {
+		key := project.ID + "|" + l.Status
+		count := bson.M{"projectid": project.ID, "status": l.Status, "_id": bson.M{"$ne": taskID}}
+		if l.PerAssignee {
+			key += "|" + assignee
+			count["assignedto"] = assignee
+		}
+
+		if _, err := locks.UpdateOne(sc, bson.M{"_id": key},
+			bson.M{"$inc": bson.M{"n": 1}}, options.Update().SetUpsert(true)); err != nil {
+			return nil, err
+		}
+
+		current, err := collection.CountDocuments(sc, count)
+		if err != nil {
+			return nil, err
+		}
+		if int(current) >= l.Limit {
+			if l.Mode == models.WIPModeWarn {
+				warnings = append(warnings, errWIPExceeded{limit: l}.Error())
+				continue
+			}
+			return nil, errWIPExceeded{limit: l}
+		}
+	}
+	return warnings, nil
+}
+
+// isWIPExceeded reports whether err is a reject-mode limit rather than a database failure
+func isWIPExceeded(err error) bool {
+	var exceeded errWIPExceeded
+	return errors.As(err, &exceeded)
+}
+
+// sendWIPError reports a failed updateTaskWithWIP. It returns false when err is nil.
+func sendWIPError(w http.ResponseWriter, err error) bool {
+	if err == nil {
+		return false
+	}
+	var exceeded errWIPExceeded
+	if errors.As(err, &exceeded) {
+		utils.SendErrorCode(w, http.StatusConflict, utils.ErrWIPLimitExceeded, exceeded.Error())
+		return true
+	}
+	utils.SendError(w, http.StatusInternalServerError, "Database error")
+	return true
+}
+
+func UpdateWIPLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ProjectID string            `json:"projectId" validate:"required"`
+		Limits    []models.WIPLimit `json:"limits" validate:"dive"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	request.ProjectID = pathParam(r, "projectId", request.ProjectID)
+	errs := utils.Validate(request)
+	if len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	project, _, ok := authorizeProject(ctx, w, r, request.ProjectID, models.ProjectRoleMaintainer)
+	if !ok {
+		return
+	}
+
+	workflow := project.EffectiveWorkflow()
+	seen := map[string]bool{}
+	for i := range request.Limits {
+		l := &request.Limits[i]
+		if !workflow.HasStatus(l.Status) {
+			errs.Add(fmt.Sprintf("limits[%d].status", i), utils.CodeInvalidChoice, "Unknown status \""+l.Status+"\" for this project")
+		}
+		if l.Mode == "" {
+			l.Mode = models.WIPModeReject
+		}
+		key := fmt.Sprintf("%s|%t", l.Status, l.PerAssignee)
+		if seen[key] {
+			errs.Add(fmt.Sprintf("limits[%d].status", i), utils.CodeConflict, "Duplicate limit for status \""+l.Status+"\"")
+		}
+		seen[key] = true
+	}
+	if len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	collection := databases.GetCollection(databases.Client, "projects")
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": project.ID}, bson.M{"$set": bson.M{
+		"wipLimits": request.Limits,
+		"updatedAt": time.Now(),
+	}})
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	utils.SendSuccess(w, "WIP limits updated", request.Limits)
+}
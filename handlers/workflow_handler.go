@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+	"trello-lite/databases"
+	"trello-lite/models"
+	"trello-lite/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// checkTransition validates a status change against the project's workflow.
+// It returns 0 when the change is allowed, otherwise the HTTP status and message to send.
+func checkTransition(project models.Project, role, from, to string) (int, string) {
+	workflow := project.EffectiveWorkflow()
+	if !workflow.HasStatus(to) {
+		return http.StatusBadRequest, "Unknown status \"" + to + "\" for this project"
+	}
+	if from == to {
+		return 0, ""
+	}
+
+	transition, ok := workflow.Transition(from, to)
+	if !ok {
+		return http.StatusConflict, "Moving a task from \"" + from + "\" to \"" + to + "\" is not allowed by the project workflow"
+	}
+	if transition.MinRole != "" && !models.ProjectRoleAtLeast(role, transition.MinRole) {
+		return http.StatusForbidden, "Moving a task to \"" + to + "\" requires project role " + transition.MinRole
+	}
+	return 0, ""
+}
+
+// sendTransitionError reports a status change refused by checkTransition
+func sendTransitionError(w http.ResponseWriter, code int, msg string) {
+	if code == http.StatusConflict {
+		utils.SendErrorCode(w, code, utils.ErrTransitionNotAllowed, msg)
+		return
+	}
+	utils.SendError(w, code, msg)
+}
+
+// openTasksFilter matches the project's tasks that are not in a done status
+func openTasksFilter(project models.Project) bson.M {
+	return bson.M{
+		"projectid": project.ID,
+		"status":    bson.M{"$nin": project.EffectiveWorkflow().DoneStatuses()},
+	}
+}
+
+// validateWorkflow checks a workflow is self-consistent before it is stored
+func validateWorkflow(workflow models.Workflow) string {
+	if len(workflow.Statuses) == 0 {
+		return "A workflow needs at least one status"
+	}
+
+	names := map[string]bool{}
+	hasDone := false
+	for _, s := range workflow.Statuses {
+		if s.Name == "" || s.Name == "*" {
+			return "Status names cannot be empty or \"*\""
+		}
+		if names[s.Name] {
+			return "Duplicate status \"" + s.Name + "\""
+		}
+		names[s.Name] = true
+		hasDone = hasDone || s.Done
+	}
+	if !hasDone {
+		return "At least one status must be marked done"
+	}
+
+	for _, t := range workflow.Transitions {
+		if t.From != "*" && !names[t.From] {
+			return "Transition from unknown status \"" + t.From + "\""
+		}
+		if !names[t.To] {
+			return "Transition to unknown status \"" + t.To + "\""
+		}
+		if t.MinRole != "" && !models.IsValidProjectRole(t.MinRole) {
+			return "Unknown minRole \"" + t.MinRole + "\""
+		}
+	}
+	return ""
+}
+
+func UpdateWorkflowHandler(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ProjectID string          `json:"projectId" validate:"required"`
+		Workflow  models.Workflow `json:"workflow" validate:"dive"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	request.ProjectID = pathParam(r, "projectId", request.ProjectID)
+	if errs := utils.Validate(request); len(errs) > 0 {
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	project, _, ok := authorizeProject(ctx, w, r, request.ProjectID, models.ProjectRoleMaintainer)
+	if !ok {
+		return
+	}
+
+	// 1. The workflow itself must make sense
+	if msg := validateWorkflow(request.Workflow); msg != "" {
+		var errs utils.ValidationErrors
+		errs.Add("workflow", utils.CodeInvalidFormat, msg)
+		utils.SendValidationErrors(w, errs)
+		return
+	}
+
+	// 2. Board columns and WIP limits can only refer to statuses that still exist
+	for _, c := range project.Columns {
+		if c.Status != "" && !request.Workflow.HasStatus(c.Status) {
+			utils.SendError(w, http.StatusConflict, "Column \""+c.Name+"\" is bound to status \""+c.Status+"\"; update the columns first")
+			return
+		}
+	}
+
+	for _, l := range project.WIPLimits {
+		if !request.Workflow.HasStatus(l.Status) {
+			utils.SendError(w, http.StatusConflict, "A WIP limit uses status \""+l.Status+"\"; update the limits first")
+			return
+		}
+	}
+
+	// 3. No task may be left in a status that is being removed
+	removed := []string{}
+	for _, s := range project.EffectiveWorkflow().Statuses {
+		if !request.Workflow.HasStatus(s.Name) {
+			removed = append(removed, s.Name)
+		}
+	}
+	if len(removed) > 0 {
+		taskColl := databases.GetCollection(databases.Client, "tasks")
+		count, err := taskColl.CountDocuments(ctx, bson.M{"projectid": project.ID, "status": bson.M{"$in": removed}})
+		if err != nil {
+			utils.SendError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if count > 0 {
+			utils.SendError(w, http.StatusConflict, "Some tasks are still in a status that would be removed")
+			return
+		}
+	}
+
+	collection := databases.GetCollection(databases.Client, "projects")
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": project.ID}, bson.M{"$set": bson.M{
+		"workflow":  request.Workflow,
+		"updatedAt": time.Now(),
+	}})
+	if err != nil {
+		utils.SendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	utils.SendSuccess(w, "Workflow updated", request.Workflow)
+}
@@ -0,0 +1,29 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// LogMailer writes messages to a writer instead of sending them, for local
+// development and tests
+type LogMailer struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func NewLogMailer(out io.Writer) *LogMailer {
+	return &LogMailer{out: out}
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := fmt.Fprintf(m.out, "--- mail %s ---\nTo: %s\nSubject: %s\n\n%s\n--- end mail ---\n",
+		time.Now().Format(time.RFC3339), msg.To, msg.Subject, msg.Body)
+	return err
+}
@@ -0,0 +1,65 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers outbound email. Handlers only depend on this interface so
+// the transport can be swapped between SMTP and a log for development.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Default is the mailer handlers use; Configure replaces it at startup
+var Default Mailer = NewLogMailer(os.Stdout)
+
+// Configure picks the mailer from the environment:
+//
+//   - MAILER=smtp uses SMTP_HOST, SMTP_PORT (default 587), SMTP_USERNAME, SMTP_PASSWORD and SMTP_FROM
+//   - MAILER=log (the default) writes messages to MAILER_LOG_FILE, or stdout if unset
+func Configure() error {
+	switch os.Getenv("MAILER") {
+	case "smtp":
+		port := 587
+		if p := os.Getenv("SMTP_PORT"); p != "" {
+			var err error
+			if port, err = strconv.Atoi(p); err != nil {
+				return fmt.Errorf("invalid SMTP_PORT: %w", err)
+			}
+		}
+		m := &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+		}
+		if m.Host == "" || m.From == "" {
+			return fmt.Errorf("MAILER=smtp requires SMTP_HOST and SMTP_FROM")
+		}
+		Default = m
+
+	case "", "log":
+		if path := os.Getenv("MAILER_LOG_FILE"); path != "" {
+			f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+			if err != nil {
+				return fmt.Errorf("opening MAILER_LOG_FILE: %w", err)
+			}
+			Default = NewLogMailer(f)
+		}
+
+	default:
+		return fmt.Errorf("unknown MAILER %q (use smtp or log)", os.Getenv("MAILER"))
+	}
+	return nil
+}
@@ -1,45 +1,55 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"log"
 	"net/http"
 	"trello-lite/databases"
 	"trello-lite/handlers"
+	"trello-lite/mailer"
 	"trello-lite/middleware"
-	"trello-lite/utils" // 1. ADD THIS IMPORT
+	"trello-lite/router"
+	"trello-lite/utils"
 	"trello-lite/workers"
 )
 
-// 2. DEFINE THE FUNCTION HERE (Outside of main)
-func handleNotFound(w http.ResponseWriter, r *http.Request) {
-	utils.SendError(w, http.StatusNotFound, "Invalid endpoint: "+r.URL.Path)
-}
-
 func main() {
+	adminEmail := flag.String("bootstrap-admin-email", "", "create or promote this account to Super Admin on startup")
+	adminPassword := flag.String("bootstrap-admin-password", "", "password for the bootstrap Super Admin")
+	adminName := flag.String("bootstrap-admin-name", "Super Admin", "display name for a newly created bootstrap Super Admin")
+	flag.Parse()
+
+	if err := utils.LoadSigningKeys(); err != nil {
+		log.Fatal("Could not load JWT signing keys: ", err)
+	}
+	if err := utils.LoadCursorKey(); err != nil {
+		log.Fatal("Could not load the cursor signing key: ", err)
+	}
+	if err := mailer.Configure(); err != nil {
+		log.Fatal("Could not configure mailer: ", err)
+	}
+
 	databases.ConnectDB()
 
+	if *adminEmail != "" {
+		if err := handlers.BootstrapSuperAdmin(*adminName, *adminEmail, *adminPassword); err != nil {
+			log.Fatal("Bootstrap admin failed: ", err)
+		}
+	}
+
 	// Background worker
 	go workers.StartOverdueScanner()
 
-	// 1. Specific Handlers
-	http.HandleFunc("/signup", middleware.AuthMiddleware(handlers.SignupHandler))
-	http.HandleFunc("/project/create", middleware.AuthMiddleware(handlers.CreateProjectHandler))
-	http.HandleFunc("/task/create", middleware.AuthMiddleware(handlers.CreateTaskHandler))
-	http.HandleFunc("/tasks", middleware.AuthMiddleware(handlers.GetTasksByProjectHandler))
-	http.HandleFunc("/task/update", middleware.AuthMiddleware(handlers.UpdateTaskStatusHandler))
-	http.HandleFunc("/task/delete", middleware.AuthMiddleware(handlers.DeleteTaskHandler))
-	http.HandleFunc("/task/search", middleware.AuthMiddleware(handlers.SearchTaskHandler))
-	http.HandleFunc("/getProject", middleware.AuthMiddleware(handlers.GetMyProjectsHandler))
-	http.HandleFunc("/taskOwnerUpdate", middleware.AuthMiddleware(handlers.UpdateTaskownerHandler))
-	http.HandleFunc("/getallusers", middleware.AuthMiddleware((handlers.GetAllUsersHandler)))
-	http.HandleFunc("/everything", middleware.AuthMiddleware(handlers.GetEverythingAggregateHandler))
-	http.HandleFunc("/login", handlers.LoginHandler)
-
-	// 2. The Catch-All Handler
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		handleNotFound(w, r)
-	})
+	// Routes: the /v1 table plus the deprecated legacy aliases (see routes.go)
+	rt := router.New()
+	policy, err := legacyPolicy()
+	if err != nil {
+		log.Fatal(err)
+	}
+	rt.Legacy = policy
+	registerRoutes(rt)
 
 	fmt.Println("Server running on :8080")
-	http.ListenAndServe(":8080", nil)
+	http.ListenAndServe(":8080", middleware.RequestID(rt))
 }
@@ -1,11 +1,16 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
+	"trello-lite/databases"
+	"trello-lite/models"
 	"trello-lite/utils"
 
-	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
@@ -17,24 +22,86 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
-		claims := &utils.Claims{}
+		tokenStr := strings.TrimPrefix(strings.TrimPrefix(authHeader, "Bearer "), "ApiKey ")
 
-		// 2. Parse and Verify the token
-		token, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
-			// Reference the key from the utils package
-			return utils.JwtKey, nil
-		})
+		// API keys are accepted alongside JWTs and are told apart by their prefix
+		if strings.HasPrefix(tokenStr, utils.APIKeyPrefix) {
+			user, key, err := authenticateAPIKey(tokenStr)
+			if err != nil {
+				utils.SendErrorCode(w, http.StatusUnauthorized, utils.ErrInvalidToken, "Invalid, expired or revoked API key")
+				return
+			}
 
-		if err != nil || !token.Valid {
-			utils.SendError(w, http.StatusUnauthorized, "Invalid or expired token")
+			r.Header.Set("User-ID", user.ID)
+			r.Header.Set("Role", user.Role)
+			r.Header.Set("Auth-Method", AuthMethodAPIKey)
+			r.Header.Set("Auth-Scopes", strings.Join(key.Scopes, ","))
+			r.Header.Del("Token-ID")
+			r.Header.Del("Token-Expires")
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// 2. Parse and Verify the token against the configured signing keys
+		claims, err := utils.ParseJWT(tokenStr)
+		if err != nil {
+			utils.SendErrorCode(w, http.StatusUnauthorized, utils.ErrInvalidToken, "Invalid or expired token")
+			return
+		}
+
+		// 3. Make sure the token has not been logged out or invalidated by a
+		// role change. The role is taken from the database so it is never
+		// staler than the token version.
+		if isTokenRevoked(claims.ID) {
+			utils.SendErrorCode(w, http.StatusUnauthorized, utils.ErrTokenRevoked, "Token has been revoked")
+			return
+		}
+		user, err := loadTokenUser(claims)
+		if err != nil {
+			utils.SendErrorCode(w, http.StatusUnauthorized, utils.ErrTokenRevoked, "Token has been revoked")
 			return
 		}
 
-		// 3. Inject verified data into headers so handlers can still use them
-		r.Header.Set("User-ID", claims.UserID)
-		r.Header.Set("Role", claims.Role)
+		// 4. Inject verified data into headers so handlers can still use them
+		r.Header.Set("User-ID", user.ID)
+		r.Header.Set("Role", user.Role)
+		r.Header.Set("Auth-Method", AuthMethodJWT)
+		r.Header.Del("Auth-Scopes")
+		r.Header.Set("Token-ID", claims.ID)
+		r.Header.Set("Token-Expires", strconv.FormatInt(claims.ExpiresAt.Unix(), 10))
 
 		next.ServeHTTP(w, r)
 	}
 }
+
+// isTokenRevoked checks the access token denylist filled by /logout
+func isTokenRevoked(jti string) bool {
+	if jti == "" {
+		return false
+	}
+
+	collection := databases.GetCollection(databases.Client, "revoked_tokens")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	count, err := collection.CountDocuments(ctx, bson.M{"_id": jti})
+	// Fail closed: if we cannot tell, treat the token as revoked
+	return err != nil || count > 0
+}
+
+// loadTokenUser returns the token's user if the token version is still current
+func loadTokenUser(claims *utils.Claims) (models.User, error) {
+	collection := databases.GetCollection(databases.Client, "users")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var user models.User
+	filter := bson.M{"_id": claims.UserID, "tokenVersion": claims.Version}
+	if claims.Version == 0 {
+		// Users created before token versioning have no tokenVersion field yet
+		filter["tokenVersion"] = bson.M{"$in": bson.A{0, nil}}
+	}
+	err := collection.FindOne(ctx, filter).Decode(&user)
+	return user, err
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"trello-lite/utils"
+)
+
+// RequestID gives every request an ID, echoed in the X-Request-ID response
+// header and in every response body. A well-formed ID sent by the client (or
+// a proxy in front of us) is kept so requests can be traced across services.
+// It also turns a panicking handler into a 500 problem response.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(utils.RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		r.Header.Set(utils.RequestIDHeader, id)
+		w.Header().Set(utils.RequestIDHeader, id)
+
+		defer func() {
+			if err := recover(); err != nil {
+				log.Printf("[%s] panic: %v\n%s", id, err, debug.Stack())
+				utils.SendError(w, http.StatusInternalServerError, "Internal server error")
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validRequestID accepts short IDs of printable, non-space ASCII only, so a
+// client cannot inject anything into logs or headers
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	buf := make([]byte, 12)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
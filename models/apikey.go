@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+)
+
+// API key scopes. A key can only call endpoints whose scope it was minted with.
+const (
+	ScopeTasksRead     = "tasks:read"
+	ScopeTasksWrite    = "tasks:write"
+	ScopeProjectsRead  = "projects:read"
+	ScopeProjectsWrite = "projects:write"
+	ScopeUsersRead     = "users:read"
+)
+
+var AllScopes = []string{ScopeTasksRead, ScopeTasksWrite, ScopeProjectsRead, ScopeProjectsWrite, ScopeUsersRead}
+
+// APIKey is a personal or service-account key. The secret is only shown once
+// at creation; afterwards the key is found by Prefix and checked against Hash.
+type APIKey struct {
+	ID         string     `json:"id" bson:"_id"`
+	Name       string     `json:"name" bson:"name"`
+	OwnerID    string     `json:"ownerId" bson:"ownerId"`
+	CreatedBy  string     `json:"createdBy" bson:"createdBy"`
+	Prefix     string     `json:"prefix" bson:"prefix"`
+	Hash       string     `json:"-" bson:"hash"`
+	Scopes     []string   `json:"scopes" bson:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt" bson:"createdAt"`
+	ExpiresAt  time.Time  `json:"expiresAt" bson:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty" bson:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty" bson:"revokedAt,omitempty"`
+}
+
+// IsValidScope reports whether scope is one of AllScopes
+func IsValidScope(scope string) bool {
+	for _, s := range AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
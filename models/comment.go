@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// Comment is a Markdown message on a task. Replies point at the comment they
+// answer through ParentID, so a task's comments form threads.
+type Comment struct {
+	ID        string        `json:"id" bson:"_id"`
+	TaskID    string        `json:"taskId" bson:"taskId"`
+	ProjectID string        `json:"projectId" bson:"projectId"`
+	ParentID  string        `json:"parentId,omitempty" bson:"parentId,omitempty"`
+	AuthorID  string        `json:"authorId" bson:"authorId"`
+	Body      string        `json:"body" bson:"body"`         // Markdown source, rendered by clients
+	Mentions  []string      `json:"mentions" bson:"mentions"` // IDs of the project members @mentioned in Body
+	Edits     []CommentEdit `json:"-" bson:"edits,omitempty"` // previous bodies, oldest first
+	Deleted   bool          `json:"deleted,omitempty" bson:"deleted,omitempty"`
+	CreatedAt time.Time     `json:"createdAt" bson:"createdAt"`
+	EditedAt  *time.Time    `json:"editedAt,omitempty" bson:"editedAt,omitempty"`
+}
+
+// CommentEdit is a body a comment had before it was edited
+type CommentEdit struct {
+	Body     string    `json:"body" bson:"body"`
+	EditedAt time.Time `json:"editedAt" bson:"editedAt"` // when this body was replaced
+	EditedBy string    `json:"editedBy" bson:"editedBy"`
+}
+
+// CommentThread is a comment with its replies, as listed on a task
+type CommentThread struct {
+	Comment `bson:",inline"`
+	Replies []CommentThread `json:"replies"`
+}
+
+// CommentRequest is the body of the create and edit endpoints
+type CommentRequest struct {
+	Body     string `json:"body" validate:"required,max=10000"`
+	ParentID string `json:"parentId"`
+}
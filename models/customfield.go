@@ -0,0 +1,66 @@
+package models
+
+import (
+	"regexp"
+)
+
+// Types of custom field
+const (
+	FieldNumber      = "number"
+	FieldText        = "text"
+	FieldDate        = "date"
+	FieldSelect      = "select"
+	FieldMultiSelect = "multiselect"
+	FieldUser        = "user"
+)
+
+// Limits on a project's custom fields and their values
+const (
+	MaxCustomFields  = 50
+	MaxFieldOptions  = 100
+	MaxFieldTextSize = 2000
+)
+
+var fieldKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// CustomField is a typed piece of metadata a project adds to its tasks, such
+// as story points or severity. Tasks store their values by field ID; Key
+// names the field in filters and sort keys (cf.<key>).
+type CustomField struct {
+	ID      string   `json:"id" bson:"id"`
+	Key     string   `json:"key" bson:"key" validate:"required,max=40"`
+	Name    string   `json:"name" bson:"name" validate:"required,max=100"`
+	Type    string   `json:"type" bson:"type" validate:"required,oneof=number|text|date|select|multiselect|user"`
+	Options []string `json:"options,omitempty" bson:"options,omitempty" validate:"max=100"` // choices of a select or multiselect, in order
+}
+
+// IsValidFieldKey reports whether key can name a custom field: lower-case
+// letters, digits and underscores, starting with a letter
+func IsValidFieldKey(key string) bool {
+	return fieldKeyPattern.MatchString(key)
+}
+
+// HasOptions reports whether the field's values are picked from Options
+func (f CustomField) HasOptions() bool {
+	return f.Type == FieldSelect || f.Type == FieldMultiSelect
+}
+
+// HasOption reports whether option is one of the field's choices
+func (f CustomField) HasOption(option string) bool {
+	for _, o := range f.Options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// CustomField returns the field with the given ID or key
+func (p Project) CustomField(idOrKey string) (CustomField, bool) {
+	for _, f := range p.CustomFields {
+		if f.ID == idOrKey || f.Key == idOrKey {
+			return f, true
+		}
+	}
+	return CustomField{}, false
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+	InvitationRevoked  = "revoked"
+)
+
+// Invitation lets a project maintainer invite someone by email, even before
+// they have an account. The emailed token is signed and names this document.
+type Invitation struct {
+	ID          string     `json:"id" bson:"_id"`
+	ProjectID   string     `json:"projectId" bson:"projectId"`
+	Email       string     `json:"email" bson:"email"`
+	Role        string     `json:"role" bson:"role"`
+	InvitedBy   string     `json:"invitedBy" bson:"invitedBy"`
+	Status      string     `json:"status" bson:"status"`
+	CreatedAt   time.Time  `json:"createdAt" bson:"createdAt"`
+	ExpiresAt   time.Time  `json:"expiresAt" bson:"expiresAt"`
+	RespondedAt *time.Time `json:"respondedAt,omitempty" bson:"respondedAt,omitempty"`
+	AcceptedBy  string     `json:"acceptedBy,omitempty" bson:"acceptedBy,omitempty"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MaxLabels caps a project's label catalog and the labels on one task
+const (
+	MaxLabels     = 100
+	MaxTaskLabels = 20
+)
+
+var labelColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Label is one entry of a project's label catalog. Tasks refer to labels by ID,
+// so renaming or recolouring a label changes it on every task at once.
+type Label struct {
+	ID    string `json:"id" bson:"id"`
+	Name  string `json:"name" bson:"name" validate:"required,max=50"`
+	Color string `json:"color" bson:"color" validate:"required"` // #rrggbb
+}
+
+// NormalizeLabelColor lower-cases a #rrggbb color. It reports false for
+// anything else.
+func NormalizeLabelColor(color string) (string, bool) {
+	if !labelColor.MatchString(color) {
+		return "", false
+	}
+	return strings.ToLower(color), true
+}
+
+// Label returns the label with the given ID
+func (p Project) Label(id string) (Label, bool) {
+	for _, l := range p.Labels {
+		if l.ID == id {
+			return l, true
+		}
+	}
+	return Label{}, false
+}
+
+// LabelNamed returns the label with the given name, ignoring case
+func (p Project) LabelNamed(name string) (Label, bool) {
+	for _, l := range p.Labels {
+		if strings.EqualFold(l.Name, name) {
+			return l, true
+		}
+	}
+	return Label{}, false
+}
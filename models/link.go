@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+)
+
+// Kinds of link between two tasks
+const (
+	LinkBlocks  = "blocks"  // From must be done before To can be
+	LinkRelates = "relates" // an undirected "see also"
+)
+
+// Modes of Workflow.OpenBlockers
+const (
+	BlockersReject = "reject"
+	BlockersWarn   = "warn"
+)
+
+// TaskLink connects two tasks, possibly in different projects. A relates
+// link is stored once, with the smaller task ID as From.
+type TaskLink struct {
+	ID            string    `json:"id" bson:"_id"`
+	Type          string    `json:"type" bson:"type"`
+	FromTaskID    string    `json:"fromTaskId" bson:"fromTaskId"`
+	FromProjectID string    `json:"fromProjectId" bson:"fromProjectId"`
+	ToTaskID      string    `json:"toTaskId" bson:"toTaskId"`
+	ToProjectID   string    `json:"toProjectId" bson:"toProjectId"`
+	CreatedBy     string    `json:"createdBy" bson:"createdBy"`
+	CreatedAt     time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// LinkRequest is the body of the create endpoint, seen from the task in the
+// path: "blocks" means it blocks TaskID, "blocked_by" that TaskID blocks it
+type LinkRequest struct {
+	Type   string `json:"type" validate:"required,oneof=blocks|blocked_by|relates"`
+	TaskID string `json:"taskId" validate:"required"`
+}
+
+// TaskRef is the short form of a task shown in links and graphs. Tasks in
+// projects the caller cannot see only carry their ID and Hidden.
+type TaskRef struct {
+	ID        string     `json:"id"`
+	Title     string     `json:"title,omitempty"`
+	Status    string     `json:"status,omitempty"`
+	ProjectID string     `json:"projectId,omitempty"`
+	DueDate   *time.Time `json:"duedate,omitempty"`
+	Done      bool       `json:"done"`
+	Hidden    bool       `json:"hidden,omitempty"`
+}
+
+// LinkedTask is the other end of one of a task's links
+type LinkedTask struct {
+	LinkID  string `json:"linkId"`
+	TaskRef `bson:",inline"`
+}
+
+// TaskDependencies are a task's direct links. Blocked is set while any
+// blocker is still open.
+type TaskDependencies struct {
+	Blocks    []LinkedTask `json:"blocks"`
+	BlockedBy []LinkedTask `json:"blockedBy"`
+	RelatesTo []LinkedTask `json:"relatesTo"`
+	Blocked   bool         `json:"blocked"`
+}
+
+// GraphEdge is a blocks link in a DependencyGraph: From blocks To
+type GraphEdge struct {
+	LinkID string `json:"linkId"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// DependencyGraph is every task a task transitively blocks or is blocked by.
+// CriticalPath is the longest chain of open tasks through the root, from the
+// first blocker to the last blocked task.
+type DependencyGraph struct {
+	Root         string      `json:"root"`
+	Nodes        []TaskRef   `json:"nodes"`
+	Edges        []GraphEdge `json:"edges"`
+	CriticalPath []string    `json:"criticalPath"`
+	Truncated    bool        `json:"truncated,omitempty"`
+}
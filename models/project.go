@@ -4,21 +4,246 @@ import (
 	"time"
 )
 
+// Per-project membership roles, from least to most privileged
+const (
+	ProjectRoleViewer     = "viewer"
+	ProjectRoleMember     = "member"
+	ProjectRoleMaintainer = "maintainer"
+	ProjectRoleOwner      = "owner"
+)
+
+var projectRoleRank = map[string]int{
+	ProjectRoleViewer:     1,
+	ProjectRoleMember:     2,
+	ProjectRoleMaintainer: 3,
+	ProjectRoleOwner:      4,
+}
+
+// IsValidProjectRole reports whether role is one of the project roles
+func IsValidProjectRole(role string) bool {
+	_, ok := projectRoleRank[role]
+	return ok
+}
+
+// ProjectRoleAtLeast reports whether role grants at least the privileges of min
+func ProjectRoleAtLeast(role, min string) bool {
+	return projectRoleRank[role] >= projectRoleRank[min] && projectRoleRank[role] > 0
+}
+
+type ProjectMember struct {
+	UserID  string    `json:"userId" bson:"userId"`
+	Role    string    `json:"role" bson:"role"`
+	AddedAt time.Time `json:"addedAt" bson:"addedAt"`
+}
+
+// Column is one list of the project's Kanban board. Columns are shown in slice
+// order. A column with a Status moves tasks into that status when they are dropped on it.
+type Column struct {
+	ID     string `json:"id" bson:"id" validate:"max=64"`
+	Name   string `json:"name" bson:"name" validate:"required,max=100"`
+	Status string `json:"status,omitempty" bson:"status,omitempty"`
+}
+
+// DefaultColumns is the board a new project starts with
+func DefaultColumns() []Column {
+	return []Column{
+		{ID: "todo", Name: "Todo", Status: "Todo"},
+		{ID: "in-progress", Name: "In Progress", Status: "In Progress"},
+		{ID: "done", Name: "Done", Status: "Done"},
+	}
+}
+
+// WorkflowStatus is one status a project's tasks can be in. Done statuses
+// count as finished: they end overdue alerts and close a task.
+type WorkflowStatus struct {
+	Name string `json:"name" bson:"name" validate:"required,max=100"`
+	Done bool   `json:"done" bson:"done"`
+}
+
+// WorkflowTransition allows moving a task from one status to another. "*" as
+// From matches any status. MinRole optionally restricts who may make the move.
+type WorkflowTransition struct {
+	From    string `json:"from" bson:"from" validate:"required"`
+	To      string `json:"to" bson:"to" validate:"required"`
+	MinRole string `json:"minRole,omitempty" bson:"minRole,omitempty" validate:"oneof=viewer|member|maintainer|owner"`
+}
+
+// Workflow is a project's set of statuses and the allowed moves between them.
+// With no transitions listed, any move between known statuses is allowed.
+type Workflow struct {
+	Statuses    []WorkflowStatus     `json:"statuses" bson:"statuses" validate:"required,dive"`
+	Transitions []WorkflowTransition `json:"transitions" bson:"transitions" validate:"dive"`
+	// RequireChildrenDone refuses moving a task to a done status while any of
+	// its subtasks is open or any checklist item is unchecked
+	RequireChildrenDone bool `json:"requireChildrenDone,omitempty" bson:"requireChildrenDone,omitempty"`
+	// OpenBlockers decides what happens when a task moves to a done status
+	// while a task blocking it is still open: "reject" refuses the move,
+	// "warn" (the default) makes it and reports a warning
+	OpenBlockers string `json:"openBlockers,omitempty" bson:"openBlockers,omitempty" validate:"oneof=reject|warn"`
+}
+
+// DefaultWorkflow applies to projects that never configured one
+func DefaultWorkflow() Workflow {
+	return Workflow{
+		Statuses: []WorkflowStatus{
+			{Name: "Todo"},
+			{Name: "In Progress"},
+			{Name: "Done", Done: true},
+		},
+	}
+}
+
+// HasStatus reports whether name is one of the workflow's statuses
+func (w Workflow) HasStatus(name string) bool {
+	for _, s := range w.Statuses {
+		if s.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDone reports whether name is a done status
+func (w Workflow) IsDone(name string) bool {
+	for _, s := range w.Statuses {
+		if s.Name == name {
+			return s.Done
+		}
+	}
+	return false
+}
+
+// DoneStatuses lists the names of every done status
+func (w Workflow) DoneStatuses() []string {
+	done := []string{}
+	for _, s := range w.Statuses {
+		if s.Done {
+			done = append(done, s.Name)
+		}
+	}
+	return done
+}
+
+// InitialStatus is the status new tasks get when none is given
+func (w Workflow) InitialStatus() string {
+	if len(w.Statuses) == 0 {
+		return ""
+	}
+	return w.Statuses[0].Name
+}
+
+// Transition finds the rule allowing from -> to. An exact From match wins over "*".
+func (w Workflow) Transition(from, to string) (WorkflowTransition, bool) {
+	if len(w.Transitions) == 0 {
+		return WorkflowTransition{From: from, To: to}, true
+	}
+
+	var wildcard *WorkflowTransition
+	for i, t := range w.Transitions {
+		if t.To != to {
+			continue
+		}
+		if t.From == from {
+			return t, true
+		}
+		if t.From == "*" && wildcard == nil {
+			wildcard = &w.Transitions[i]
+		}
+	}
+	if wildcard != nil {
+		return *wildcard, true
+	}
+	return WorkflowTransition{}, false
+}
+
+const (
+	WIPModeReject = "reject"
+	WIPModeWarn   = "warn"
+)
+
+// WIPLimit caps how many tasks may be in Status at once, across the project
+// or, with PerAssignee, per person. Mode decides whether going over is
+// refused or only reported back as a warning.
+type WIPLimit struct {
+	Status      string `json:"status" bson:"status" validate:"required"`
+	Limit       int    `json:"limit" bson:"limit" validate:"min=1"`
+	PerAssignee bool   `json:"perAssignee" bson:"perAssignee"`
+	Mode        string `json:"mode" bson:"mode" validate:"oneof=reject|warn"`
+}
+
 type Project struct {
-	ID          string    `json:"id" bson:"_id"`
-	Name        string    `json:"name" bson:"name"`
-	Description string    `json:"description" bson:"description"`
-	OwnerID     string    `json:"ownerId" bson:"ownerId"`
-	MemberIDs   []string  `json:"memberIds" bson:"memberIds"`
-	CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
+	ID           string          `json:"id" bson:"_id"`
+	Name         string          `json:"name" bson:"name" validate:"required,max=100"`
+	Description  string          `json:"description" bson:"description" validate:"max=2000"`
+	OwnerID      string          `json:"ownerId" bson:"ownerId"`
+	MemberIDs    []string        `json:"memberIds" bson:"memberIds"`
+	Memberships  []ProjectMember `json:"memberships" bson:"memberships"`
+	Columns      []Column        `json:"columns" bson:"columns" validate:"dive"`
+	Workflow     *Workflow       `json:"workflow,omitempty" bson:"workflow,omitempty" validate:"dive"`
+	WIPLimits    []WIPLimit      `json:"wipLimits,omitempty" bson:"wipLimits,omitempty" validate:"dive"`
+	Labels       []Label         `json:"labels,omitempty" bson:"labels,omitempty" validate:"max=100,dive"`
+	CustomFields []CustomField   `json:"customFields,omitempty" bson:"customFields,omitempty" validate:"max=50,dive"`
+	Archived     bool            `json:"archived" bson:"archived"`
+	ArchivedAt   *time.Time      `json:"archivedAt,omitempty" bson:"archivedAt,omitempty"`
+	CreatedAt    time.Time       `json:"createdAt" bson:"createdAt"`
+	UpdatedAt    time.Time       `json:"updatedAt" bson:"updatedAt"`
+}
+
+// EffectiveWorkflow returns the project's workflow, or the default one
+func (p Project) EffectiveWorkflow() Workflow {
+	if p.Workflow != nil && len(p.Workflow.Statuses) > 0 {
+		return *p.Workflow
+	}
+	return DefaultWorkflow()
+}
+
+// Column returns the column with the given ID
+func (p Project) Column(id string) (Column, bool) {
+	for _, c := range p.Columns {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return Column{}, false
+}
+
+// RoleOf returns userID's role in the project, or "" if they are not part of it.
+// MemberIDs entries without a membership record predate project roles and count as members.
+func (p Project) RoleOf(userID string) string {
+	if userID == "" {
+		return ""
+	}
+	if p.OwnerID == userID {
+		return ProjectRoleOwner
+	}
+	for _, m := range p.Memberships {
+		if m.UserID == userID {
+			return m.Role
+		}
+	}
+	for _, id := range p.MemberIDs {
+		if id == userID {
+			return ProjectRoleMember
+		}
+	}
+	return ""
 }
 
 type ProjectDetailResponse struct {
-	ID          string    `json:"id" bson:"id"`
-	Name        string    `json:"name" bson:"name"`
-	Description string    `json:"description" bson:"description"`
-	OwnerID     string    `json:"ownerId" bson:"ownerId"`
-	MemberIDs   []string  `json:"memberIds" bson:"memberIds"`
-	Members     []User    `json:"members" bson:"members"`
-	CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
+	ID           string          `json:"id" bson:"id"`
+	Name         string          `json:"name" bson:"name"`
+	Description  string          `json:"description" bson:"description"`
+	OwnerID      string          `json:"ownerId" bson:"ownerId"`
+	MemberIDs    []string        `json:"memberIds" bson:"memberIds"`
+	Memberships  []ProjectMember `json:"memberships" bson:"memberships"`
+	Members      []User          `json:"members" bson:"members"`
+	Columns      []Column        `json:"columns" bson:"columns"`
+	Workflow     *Workflow       `json:"workflow,omitempty" bson:"workflow,omitempty"`
+	WIPLimits    []WIPLimit      `json:"wipLimits,omitempty" bson:"wipLimits,omitempty"`
+	Labels       []Label         `json:"labels,omitempty" bson:"labels,omitempty"`
+	CustomFields []CustomField   `json:"customFields,omitempty" bson:"customFields,omitempty"`
+	Archived     bool            `json:"archived" bson:"archived"`
+	ArchivedAt   *time.Time      `json:"archivedAt,omitempty" bson:"archivedAt,omitempty"`
+	CreatedAt    time.Time       `json:"createdAt" bson:"createdAt"`
+	UpdatedAt    time.Time       `json:"updatedAt" bson:"updatedAt"`
 }
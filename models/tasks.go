@@ -1,18 +1,86 @@
 package models
 
 import (
+	"strings"
 	"time"
 )
 
+// Task priorities, from least to most pressing
+const (
+	PriorityLow    = "Low"
+	PriorityMedium = "Medium"
+	PriorityHigh   = "High"
+	PriorityUrgent = "Urgent"
+)
+
+var priorities = []string{PriorityLow, PriorityMedium, PriorityHigh, PriorityUrgent}
+
+// NormalizePriority maps a priority in any letter case to its canonical
+// spelling. It reports false for values that are not a priority.
+func NormalizePriority(priority string) (string, bool) {
+	for _, p := range priorities {
+		if strings.EqualFold(p, priority) {
+			return p, true
+		}
+	}
+	return "", false
+}
+
 type Task struct {
 	ID          string    `json:"id" bson:"_id,omitempty"`
-	Title       string    `json:"title" bson:"title"`
-	Description string    `json:"description" bson:"description"`
-	Status      string    `json:"status" bson:"status"`
-	Priority    string    `json:"priority" bson:"priority"`
+	Title       string    `json:"title" bson:"title" validate:"required,max=200"`
+	Description string    `json:"description" bson:"description" validate:"max=10000"`
+	Status      string    `json:"status" bson:"status" validate:"max=100"`
+	Priority    string    `json:"priority" bson:"priority" validate:"oneof=Low|Medium|High|Urgent"`
 	DueDate     time.Time `json:"duedate" bson:"duedate"`
-	ProjectId   string    `json:"projectid" bson:"projectid"`
+	ProjectId   string    `json:"projectid" bson:"projectid" validate:"required"`
 	AssignedTo  string    `json:"assignedto" bson:"assignedto"`
+	ColumnID    string    `json:"columnId" bson:"columnId"`
+	Rank        string    `json:"rank" bson:"rank"`       // lexicographic position within the column
+	Version     int64     `json:"version" bson:"version"` // bumped on every write, exposed as the ETag
 	CreatedAt   time.Time `json:"createdat" bson:"createdat"`
 	UpdatedAt   time.Time `json:"updatedat" bson:"updatedat"`
+
+	ParentID  string          `json:"parentId,omitempty" bson:"parentId,omitempty"` // set on subtasks; never changes
+	Checklist []ChecklistItem `json:"checklist,omitempty" bson:"checklist,omitempty" validate:"max=100,dive"`
+	Labels    []string        `json:"labels,omitempty" bson:"labels,omitempty" validate:"max=20"` // IDs from the project's label catalog
+	// CustomFields holds the values of the project's custom fields by field ID
+	CustomFields map[string]interface{} `json:"customFields,omitempty" bson:"customFields,omitempty"`
+	Progress     *TaskProgress          `json:"progress,omitempty" bson:"-"` // computed on read
+
+	Dependencies *TaskDependencies `json:"dependencies,omitempty" bson:"-"` // only with ?include=dependencies
+}
+
+// ChecklistItem is one step of a task's checklist, kept in display order
+type ChecklistItem struct {
+	ID         string     `json:"id" bson:"id"`
+	Text       string     `json:"text" bson:"text" validate:"required,max=500"`
+	Done       bool       `json:"done" bson:"done"`
+	AssignedTo string     `json:"assignedTo,omitempty" bson:"assignedTo,omitempty"`
+	DueDate    *time.Time `json:"dueDate,omitempty" bson:"dueDate,omitempty"`
+}
+
+// Progress counts finished parts out of all parts
+type Progress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// TaskProgress rolls up a task's checklist and its direct subtasks
+type TaskProgress struct {
+	Checklist Progress `json:"checklist"`
+	Subtasks  Progress `json:"subtasks"`
+	Done      int      `json:"done"`
+	Total     int      `json:"total"`
+}
+
+// ChecklistProgress counts the checked items of a checklist
+func ChecklistProgress(items []ChecklistItem) Progress {
+	p := Progress{Total: len(items)}
+	for _, item := range items {
+		if item.Done {
+			p.Done++
+		}
+	}
+	return p
 }
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// RefreshToken is stored by the hash of the opaque token handed to the client.
+// Every rotation creates a new token in the same family; presenting an already
+// rotated token revokes the whole family.
+type RefreshToken struct {
+	ID           string     `json:"-" bson:"_id"`
+	UserID       string     `json:"userId" bson:"userId"`
+	FamilyID     string     `json:"familyId" bson:"familyId"`
+	TokenVersion int        `json:"-" bson:"tokenVersion"`
+	CreatedAt    time.Time  `json:"createdAt" bson:"createdAt"`
+	ExpiresAt    time.Time  `json:"expiresAt" bson:"expiresAt"`
+	RevokedAt    *time.Time `json:"revokedAt,omitempty" bson:"revokedAt,omitempty"`
+}
+
+// RevokedToken is a denylisted access token, kept until the token would have expired anyway
+type RevokedToken struct {
+	ID        string    `bson:"_id"` // the token's jti
+	UserID    string    `bson:"userId"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
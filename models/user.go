@@ -4,11 +4,29 @@ import (
 	"time"
 )
 
+// Global roles carried in the JWT
+const (
+	RoleUser       = "User"
+	RoleAdmin      = "Admin"
+	RoleSuperAdmin = "Super Admin"
+)
+
+// UserTypeService marks non-human accounts that can own API keys but cannot log in
+const UserTypeService = "service"
+
 type User struct {
 	// The underscore is mandatory for MongoDB's primary key
-	ID        string    `json:"id" bson:"_id"`
-	Name      string    `json:"name" bson:"name"`
-	Email     string    `json:"email" bson:"email"`
-	Role      string    `json:"role" bson:"role"`
-	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	ID           string    `json:"id" bson:"_id"`
+	Name         string    `json:"name" bson:"name"`
+	Email        string    `json:"email" bson:"email"`
+	Role         string    `json:"role" bson:"role"`
+	Type         string    `json:"type,omitempty" bson:"type,omitempty"`
+	PasswordHash string    `json:"-" bson:"passwordHash"` // bcrypt hash, never sent to clients
+	TokenVersion int       `json:"-" bson:"tokenVersion"` // bumped to invalidate every token issued so far
+	CreatedAt    time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// IsValidRole reports whether role is one of the global roles
+func IsValidRole(role string) bool {
+	return role == RoleUser || role == RoleAdmin || role == RoleSuperAdmin
 }
@@ -0,0 +1,257 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// compile builds the condition for one term
+func compile(field Field, name string, op token, values []token, env Env) (bson.M, error) {
+	unsupported := &Error{Pos: op.pos, Msg: fmt.Sprintf("operator %s is not supported for %s", op.text, name)}
+
+	switch field.Kind {
+	case Date:
+		if len(values) > 1 || op.text == "in" || op.text == "not in" {
+			return nil, unsupported
+		}
+		return compileDate(field.Path, op.text, values[0], env)
+
+	case Label:
+		// A task has any number of labels: label:bug means one of them is bug
+		ids := bson.A{}
+		for _, v := range values {
+			ids = append(ids, env.LabelIDs(v.text)...)
+		}
+		switch op.text {
+		case ":", "=", "in":
+			return bson.M{field.Path: bson.M{"$in": ids}}, nil
+		case "!=", "not in":
+			return bson.M{field.Path: bson.M{"$nin": ids}}, nil
+		}
+		return nil, unsupported
+
+	case Number:
+		return compileNumber(field.Path, name, op, values)
+
+	case Text:
+		switch op.text {
+		case ":":
+			return bson.M{field.Path: containsRegex(values[0].text)}, nil
+		case "=":
+			return bson.M{field.Path: values[0].text}, nil
+		case "!=":
+			return bson.M{field.Path: bson.M{"$not": containsRegex(values[0].text)}}, nil
+		}
+		return nil, unsupported
+	}
+
+	// String, User and Enum compare exact values
+	literals := make([]interface{}, len(values))
+	for i, v := range values {
+		literal, err := resolveValue(field, name, v, env)
+		if err != nil {
+			return nil, err
+		}
+		literals[i] = literal
+	}
+
+	switch op.text {
+	case ":", "=":
+		return bson.M{field.Path: literals[0]}, nil
+	case "!=":
+		return bson.M{field.Path: bson.M{"$ne": literals[0]}}, nil
+	case "in":
+		return bson.M{field.Path: bson.M{"$in": literals}}, nil
+	case "not in":
+		return bson.M{field.Path: bson.M{"$nin": literals}}, nil
+	}
+
+	// Only enums have an order: priority>Medium means High or Urgent
+	if field.Kind != Enum || literals[0] == nil {
+		return nil, unsupported
+	}
+	idx := indexOf(field.Values, literals[0].(string))
+	var matching []string
+	for i, v := range field.Values {
+		if (op.text == "<" && i < idx) || (op.text == "<=" && i <= idx) ||
+			(op.text == ">" && i > idx) || (op.text == ">=" && i >= idx) {
+			matching = append(matching, v)
+		}
+	}
+	return bson.M{field.Path: bson.M{"$in": matching}}, nil
+}
+
+// resolveValue checks an exact value and applies the field's special words
+func resolveValue(field Field, name string, v token, env Env) (interface{}, error) {
+	if field.Sparse && strings.EqualFold(v.text, "none") {
+		return nil, nil
+	}
+	switch field.Kind {
+	case User:
+		switch strings.ToLower(v.text) {
+		case "me":
+			return env.UserID, nil
+		case "none":
+			return "", nil
+		}
+	case Enum:
+		for _, allowed := range field.Values {
+			if strings.EqualFold(allowed, v.text) {
+				return allowed, nil
+			}
+		}
+		return nil, &Error{Pos: v.pos, Msg: fmt.Sprintf("%s is not a valid %s; use one of %s", v.describe(), name, strings.Join(field.Values, ", "))}
+	}
+	return v.text, nil
+}
+
+// compileNumber compares a number field. "none" only works with the
+// equality operators.
+func compileNumber(path, name string, op token, values []token) (bson.M, error) {
+	numbers := make(bson.A, len(values))
+	for i, v := range values {
+		if strings.EqualFold(v.text, "none") {
+			if op.text != ":" && op.text != "=" && op.text != "!=" && op.text != "in" && op.text != "not in" {
+				return nil, &Error{Pos: v.pos, Msg: fmt.Sprintf("none cannot be compared with %s", op.text)}
+			}
+			numbers[i] = nil
+			continue
+		}
+		n, err := strconv.ParseFloat(v.text, 64)
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			return nil, &Error{Pos: v.pos, Msg: fmt.Sprintf("%s is not a valid %s; use a number", v.describe(), name)}
+		}
+		numbers[i] = n
+	}
+
+	switch op.text {
+	case ":", "=":
+		return bson.M{path: numbers[0]}, nil
+	case "!=":
+		return bson.M{path: bson.M{"$ne": numbers[0]}}, nil
+	case "in":
+		return bson.M{path: bson.M{"$in": numbers}}, nil
+	case "not in":
+		return bson.M{path: bson.M{"$nin": numbers}}, nil
+	}
+	ops := map[string]string{"<": "$lt", "<=": "$lte", ">": "$gt", ">=": "$gte"}
+	return bson.M{path: bson.M{ops[op.text]: numbers[0]}}, nil
+}
+
+func containsRegex(s string) primitive.Regex {
+	return primitive.Regex{Pattern: regexp.QuoteMeta(s), Options: "i"}
+}
+
+func indexOf(values []string, v string) int {
+	for i, candidate := range values {
+		if candidate == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// compileDate compares against an instant, or against a whole UTC day
+func compileDate(path, op string, v token, env Env) (bson.M, error) {
+	// Tasks without a date store the zero time; older documents may lack the field
+	if strings.EqualFold(v.text, "none") {
+		switch op {
+		case ":", "=":
+			return bson.M{path: bson.M{"$in": bson.A{time.Time{}, nil}}}, nil
+		case "!=":
+			return bson.M{path: bson.M{"$nin": bson.A{time.Time{}, nil}}}, nil
+		}
+		return nil, &Error{Pos: v.pos, Msg: "none can only be compared with :, = or !="}
+	}
+
+	start, end, err := resolveDate(v, env)
+	if err != nil {
+		return nil, err
+	}
+
+	var cond bson.M
+	switch op {
+	case ":", "=":
+		if start.Equal(end) {
+			return bson.M{path: start}, nil
+		}
+		return bson.M{path: bson.M{"$gte": start, "$lt": end}}, nil
+	case "!=":
+		if start.Equal(end) {
+			return bson.M{path: bson.M{"$ne": start}}, nil
+		}
+		return bson.M{path: bson.M{"$not": bson.M{"$gte": start, "$lt": end}}}, nil
+	case "<":
+		cond = bson.M{"$lt": start}
+	case "<=":
+		cond = bson.M{"$lt": end}
+		if start.Equal(end) {
+			cond = bson.M{"$lte": end}
+		}
+	case ">":
+		cond = bson.M{"$gte": end}
+		if start.Equal(end) {
+			cond = bson.M{"$gt": end}
+		}
+	case ">=":
+		cond = bson.M{"$gte": start}
+	}
+	if _, hasLower := cond["$gte"]; !hasLower {
+		if _, hasGreater := cond["$gt"]; !hasGreater {
+			// "before" never matches the zero time used for "no date"
+			cond["$gt"] = time.Time{}
+		}
+	}
+	return bson.M{path: cond}, nil
+}
+
+var relativeDate = regexp.MustCompile(`^([+-]?\d{1,4})([hdw])$`)
+
+// resolveDate returns the range a date value covers: a whole day
+// [start, end), or a single instant where start == end
+func resolveDate(v token, env Env) (time.Time, time.Time, error) {
+	now := env.Now.UTC()
+	today := now.Truncate(24 * time.Hour)
+	day := func(t time.Time) (time.Time, time.Time, error) {
+		return t, t.AddDate(0, 0, 1), nil
+	}
+
+	switch strings.ToLower(v.text) {
+	case "now":
+		return now, now, nil
+	case "today":
+		return day(today)
+	case "yesterday":
+		return day(today.AddDate(0, 0, -1))
+	case "tomorrow":
+		return day(today.AddDate(0, 0, 1))
+	}
+
+	if m := relativeDate.FindStringSubmatch(strings.ToLower(v.text)); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		switch m[2] {
+		case "h":
+			t := now.Add(time.Duration(n) * time.Hour)
+			return t, t, nil
+		case "d":
+			return day(today.AddDate(0, 0, n))
+		case "w":
+			return day(today.AddDate(0, 0, 7*n))
+		}
+	}
+
+	if t, err := time.Parse("2006-01-02", v.text); err == nil {
+		return day(t)
+	}
+	if t, err := time.Parse(time.RFC3339, v.text); err == nil {
+		return t.UTC(), t.UTC(), nil
+	}
+	return time.Time{}, time.Time{}, &Error{Pos: v.pos, Msg: fmt.Sprintf("%s is not a date; use YYYY-MM-DD, a quoted RFC 3339 time, today, now, or an offset such as 7d, -2w or 12h", v.describe())}
+}
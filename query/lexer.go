@@ -0,0 +1,109 @@
+package query
+
+import (
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+// token is one lexeme; pos is its 1-based character position in the filter
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) describe() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of filter"
+	case tokString:
+		return `"` + t.text + `"`
+	default:
+		return "\"" + t.text + "\""
+	}
+}
+
+// isWordRune reports whether r can appear in a bare word. Anything else is
+// punctuation, so values containing it (spaces, colons) must be quoted.
+func isWordRune(r rune) bool {
+	return !unicode.IsSpace(r) && !strings.ContainsRune(`():,=!<>"`, r)
+}
+
+func lex(src string) ([]token, error) {
+	runes := []rune(src)
+	var toks []token
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		pos := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '(':
+			toks = append(toks, token{tokLParen, "(", pos})
+			i++
+		case r == ')':
+			toks = append(toks, token{tokRParen, ")", pos})
+			i++
+		case r == ',':
+			toks = append(toks, token{tokComma, ",", pos})
+			i++
+
+		case r == ':' || r == '=':
+			toks = append(toks, token{tokOp, string(r), pos})
+			i++
+		case r == '<' || r == '>' || r == '!':
+			op := string(r)
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, &Error{Pos: pos, Msg: `expected "!=" but found "!"`}
+			}
+			toks = append(toks, token{tokOp, op, pos})
+			i += len(op)
+
+		case r == '"':
+			var sb strings.Builder
+			i++
+			for {
+				if i >= len(runes) {
+					return nil, &Error{Pos: pos, Msg: "unterminated quoted value"}
+				}
+				if runes[i] == '\\' && i+1 < len(runes) {
+					sb.WriteRune(runes[i+1])
+					i += 2
+					continue
+				}
+				if runes[i] == '"' {
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			toks = append(toks, token{tokString, sb.String(), pos})
+
+		default:
+			start := i
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+			toks = append(toks, token{tokWord, string(runes[start:i]), pos})
+		}
+	}
+	return append(toks, token{tokEOF, "", len(runes) + 1}), nil
+}
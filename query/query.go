@@ -0,0 +1,311 @@
+// Package query parses the filter expressions accepted by the task list and
+// search endpoints, e.g.
+//
+//	status:Todo AND assignee:me AND due<7d AND priority in (High,Urgent)
+//
+// into a MongoDB filter. Only fields listed in a Schema can be queried and
+// every value is used as a literal, so users cannot inject Mongo operators.
+//
+// Grammar (keywords are case-insensitive, AND may be left out):
+//
+//	expr   = and { "OR" and }
+//	and    = not { ["AND"] not }
+//	not    = "NOT" not | "(" expr ")" | term
+//	term   = field op value | field ["NOT"] "IN" "(" value { "," value } ")"
+//	op     = ":" | "=" | "!=" | "<" | "<=" | ">" | ">="
+//	value  = word | "quoted string"
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Limits keep a single filter cheap to parse and to run
+const (
+	MaxLength = 1000
+	maxTerms  = 50
+	maxDepth  = 10
+	maxValues = 100
+)
+
+// Kind decides which operators a field supports and how its values are read
+type Kind int
+
+const (
+	// String compares exact values
+	String Kind = iota
+	// Text matches a case-insensitive substring with ":", an exact value with "="
+	Text
+	// Enum is a fixed, ordered set of values; < and > compare by that order
+	Enum
+	// User takes user IDs, "me" for the caller and "none" for nobody
+	User
+	// Date takes dates, times and relative dates such as 7d (see Parse)
+	Date
+	// Label matches an array of label IDs. Values are label names, looked
+	// up in Env.Labels, or IDs, and "none" matches tasks without labels.
+	Label
+	// Number compares numbers; "none" matches a missing value
+	Number
+)
+
+// Field is a queryable field and the document path it maps to
+type Field struct {
+	Path   string
+	Kind   Kind
+	Values []string // the allowed values of an Enum, lowest first
+	// Sparse fields are left out of documents that have no value, so "none"
+	// matches a missing field instead of an empty value
+	Sparse bool
+}
+
+// Schema lists the fields a filter may use, keyed by lower-case name
+type Schema map[string]Field
+
+// Env is what values such as "me" and "7d" are resolved against
+type Env struct {
+	UserID string
+	Now    time.Time
+	Labels map[string][]string // label IDs by lower-case label name
+}
+
+// LabelIDs resolves one value of a Label field: the IDs of the labels with
+// that name, or the value itself taken as an ID. "none" stands for no labels.
+func (env Env) LabelIDs(value string) bson.A {
+	name := strings.ToLower(value)
+	if name == "none" {
+		return bson.A{nil, bson.A{}}
+	}
+	ids := bson.A{}
+	for _, id := range env.Labels[name] {
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		ids = append(ids, value)
+	}
+	return ids
+}
+
+// Error is a problem with a filter expression. Pos is the 1-based character
+// position where it was found.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (at position %d)", e.Msg, e.Pos)
+}
+
+// Parse turns a filter expression into a MongoDB filter. Date values can be
+// YYYY-MM-DD, a quoted RFC 3339 time, today, yesterday, tomorrow, now, or an
+// offset from now such as 7d, -2w or 12h. Day values cover the whole (UTC)
+// day, so due:today matches anything due today and due<3d anything due
+// before the start of the day three days from now.
+func Parse(src string, schema Schema, env Env) (bson.M, error) {
+	if len([]rune(src)) > MaxLength {
+		return nil, &Error{Pos: MaxLength + 1, Msg: fmt.Sprintf("filter is longer than %d characters", MaxLength)}
+	}
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	if toks[0].kind == tokEOF {
+		return nil, &Error{Pos: 1, Msg: "filter is empty"}
+	}
+
+	p := &parser{toks: toks, schema: schema, env: env}
+	filter, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, &Error{Pos: t.pos, Msg: "unexpected " + t.describe()}
+	}
+	return filter, nil
+}
+
+type parser struct {
+	toks   []token
+	i      int
+	schema Schema
+	env    Env
+	terms  int
+	depth  int
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func isKeyword(t token, kw string) bool {
+	return t.kind == tokWord && strings.EqualFold(t.text, kw)
+}
+
+func (p *parser) parseOr() (bson.M, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	parts := bson.A{left}
+	for isKeyword(p.peek(), "OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, right)
+	}
+	if len(parts) == 1 {
+		return left, nil
+	}
+	return bson.M{"$or": parts}, nil
+}
+
+func (p *parser) parseAnd() (bson.M, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	parts := bson.A{left}
+	for {
+		t := p.peek()
+		if isKeyword(t, "AND") {
+			p.next()
+		} else if t.kind != tokLParen && (t.kind != tokWord || isKeyword(t, "OR")) {
+			break
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, right)
+	}
+	if len(parts) == 1 {
+		return left, nil
+	}
+	return bson.M{"$and": parts}, nil
+}
+
+func (p *parser) parseNot() (bson.M, error) {
+	t := p.peek()
+	switch {
+	case isKeyword(t, "NOT"):
+		p.next()
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return bson.M{"$nor": bson.A{inner}}, nil
+
+	case t.kind == tokLParen:
+		if p.depth++; p.depth > maxDepth {
+			return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("parentheses are nested more than %d deep", maxDepth)}
+		}
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, &Error{Pos: closing.pos, Msg: "expected \")\" to close the \"(\" at position " + fmt.Sprint(t.pos) + " but found " + closing.describe()}
+		}
+		p.depth--
+		return inner, nil
+
+	case t.kind == tokWord:
+		return p.parseTerm()
+	}
+	return nil, &Error{Pos: t.pos, Msg: "expected a field name but found " + t.describe()}
+}
+
+func (p *parser) parseTerm() (bson.M, error) {
+	name := p.next()
+	if p.terms++; p.terms > maxTerms {
+		return nil, &Error{Pos: name.pos, Msg: fmt.Sprintf("filter has more than %d conditions", maxTerms)}
+	}
+	field, ok := p.schema[strings.ToLower(name.text)]
+	if !ok {
+		return nil, &Error{Pos: name.pos, Msg: fmt.Sprintf("unknown field %q; use one of %s", name.text, p.fieldNames())}
+	}
+
+	opTok := p.next()
+	switch {
+	case opTok.kind == tokOp:
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return compile(field, name.text, opTok, []token{value}, p.env)
+
+	case isKeyword(opTok, "NOT") && isKeyword(p.peek(), "IN"):
+		p.next()
+		opTok.text = "not in"
+	case isKeyword(opTok, "IN"):
+		opTok.text = "in"
+	default:
+		return nil, &Error{Pos: opTok.pos, Msg: "expected an operator (:, =, !=, <, <=, >, >=, in) after " + name.text + " but found " + opTok.describe()}
+	}
+
+	values, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	return compile(field, name.text, opTok, values, p.env)
+}
+
+func (p *parser) parseValue() (token, error) {
+	t := p.next()
+	if t.kind != tokWord && t.kind != tokString {
+		return t, &Error{Pos: t.pos, Msg: "expected a value but found " + t.describe()}
+	}
+	return t, nil
+}
+
+// parseList reads ( value, value, ... )
+func (p *parser) parseList() ([]token, error) {
+	open := p.next()
+	if open.kind != tokLParen {
+		return nil, &Error{Pos: open.pos, Msg: "expected \"(\" to start a list but found " + open.describe()}
+	}
+	var values []token
+	for {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if values = append(values, value); len(values) > maxValues {
+			return nil, &Error{Pos: value.pos, Msg: fmt.Sprintf("list has more than %d values", maxValues)}
+		}
+		switch sep := p.next(); sep.kind {
+		case tokComma:
+			continue
+		case tokRParen:
+			return values, nil
+		default:
+			return nil, &Error{Pos: sep.pos, Msg: "expected \",\" or \")\" in list but found " + sep.describe()}
+		}
+	}
+}
+
+func (p *parser) fieldNames() string {
+	names := make([]string, 0, len(p.schema))
+	for name := range p.schema {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
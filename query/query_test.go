@@ -0,0 +1,136 @@
+package query
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var testSchema = Schema{
+	"status":   {Path: "status", Kind: String},
+	"title":    {Path: "title", Kind: Text},
+	"assignee": {Path: "assignedto", Kind: User},
+	"priority": {Path: "priority", Kind: Enum, Values: []string{"Low", "Medium", "High", "Urgent"}},
+	"due":      {Path: "duedate", Kind: Date},
+	"label":    {Path: "labels", Kind: Label},
+	"points":   {Path: "customFields.p", Kind: Number, Sparse: true},
+	"severity": {Path: "customFields.s", Kind: Enum, Values: []string{"S1", "S2"}, Sparse: true},
+}
+
+var testEnv = Env{
+	UserID: "u1",
+	Now:    time.Date(2026, 10, 17, 15, 30, 0, 0, time.UTC),
+	Labels: map[string][]string{"bug": {"l1", "l2"}},
+}
+
+func day(d int) time.Time {
+	return time.Date(2026, 10, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bson.M
+	}{
+		{"status:Todo", bson.M{"status": "Todo"}},
+		{"status:Todo assignee:me", bson.M{"$and": bson.A{bson.M{"status": "Todo"}, bson.M{"assignedto": "u1"}}}},
+		{"status:Todo or not title:x", bson.M{"$or": bson.A{
+			bson.M{"status": "Todo"},
+			bson.M{"$nor": bson.A{bson.M{"title": primitive.Regex{Pattern: "x", Options: "i"}}}},
+		}}},
+		{"(status:a OR status:b) AND NOT assignee:me", bson.M{"$and": bson.A{
+			bson.M{"$or": bson.A{bson.M{"status": "a"}, bson.M{"status": "b"}}},
+			bson.M{"$nor": bson.A{bson.M{"assignedto": "u1"}}},
+		}}},
+		{`title="a b"`, bson.M{"title": "a b"}},
+		{"title:a.b", bson.M{"title": primitive.Regex{Pattern: `a\.b`, Options: "i"}}},
+		{"assignee:none", bson.M{"assignedto": ""}},
+		{"assignee in (me, u2)", bson.M{"assignedto": bson.M{"$in": []interface{}{"u1", "u2"}}}},
+		{"priority>Medium", bson.M{"priority": bson.M{"$in": []string{"High", "Urgent"}}}},
+		{"priority in (low, HIGH)", bson.M{"priority": bson.M{"$in": []interface{}{"Low", "High"}}}},
+		{"due:today", bson.M{"duedate": bson.M{"$gte": day(17), "$lt": day(18)}}},
+		{"due<7d", bson.M{"duedate": bson.M{"$gt": time.Time{}, "$lt": day(24)}}},
+		{"due:none", bson.M{"duedate": bson.M{"$in": bson.A{time.Time{}, nil}}}},
+		{`due>="2026-11-01T09:00:00Z"`, bson.M{"duedate": bson.M{"$gte": time.Date(2026, 11, 1, 9, 0, 0, 0, time.UTC)}}},
+		{"label:bug", bson.M{"labels": bson.M{"$in": bson.A{"l1", "l2"}}}},
+		{"label:l9", bson.M{"labels": bson.M{"$in": bson.A{"l9"}}}},
+		{"label:none", bson.M{"labels": bson.M{"$in": bson.A{nil, bson.A{}}}}},
+		{"label != bug", bson.M{"labels": bson.M{"$nin": bson.A{"l1", "l2"}}}},
+		{"points>2.5", bson.M{"customFields.p": bson.M{"$gt": 2.5}}},
+		{"points in (1, 2)", bson.M{"customFields.p": bson.M{"$in": bson.A{1.0, 2.0}}}},
+		{"points:none", bson.M{"customFields.p": nil}},
+		{"severity:none", bson.M{"customFields.s": nil}},
+		// Values are always literals, never operators
+		{"status:$ne", bson.M{"status": "$ne"}},
+		{`status:"{\"$ne\":1}"`, bson.M{"status": `{"$ne":1}`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got, err := Parse(tt.src, testSchema, testEnv)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.src, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q)\n got %#v\nwant %#v", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		pos int
+	}{
+		{"", 1},
+		{"foo:bar", 1},
+		{"status:", 8},
+		{"status:a AND", 13},
+		{"priority:Huge", 10},
+		{"title<x", 6},
+		{"due in (today)", 5},
+		{"due:someday", 5},
+		{"points<none", 8},
+		{"points:abc", 8},
+		{"(status:a", 10},
+		{"status:a)", 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := Parse(tt.src, testSchema, testEnv)
+			var qerr *Error
+			if !errors.As(err, &qerr) {
+				t.Fatalf("Parse(%q) error = %v, want a *query.Error", tt.src, err)
+			}
+			if qerr.Pos != tt.pos {
+				t.Errorf("Parse(%q) error at %d, want %d: %v", tt.src, qerr.Pos, tt.pos, err)
+			}
+		})
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	long := make([]byte, MaxLength+1)
+	for i := range long {
+		long[i] = 'a'
+	}
+	if _, err := Parse(string(long), testSchema, testEnv); err == nil {
+		t.Error("Parse accepted a filter longer than MaxLength")
+	}
+
+	deep := ""
+	for i := 0; i <= maxDepth; i++ {
+		deep += "("
+	}
+	deep += "status:a"
+	for i := 0; i <= maxDepth; i++ {
+		deep += ")"
+	}
+	if _, err := Parse(deep, testSchema, testEnv); err == nil {
+		t.Error("Parse accepted a filter nested deeper than maxDepth")
+	}
+}
@@ -0,0 +1,89 @@
+package router
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"trello-lite/utils"
+)
+
+// Middleware wraps a handler, e.g. with authentication and scope checks
+type Middleware func(http.HandlerFunc) http.HandlerFunc
+
+// Router matches requests by path with http.ServeMux patterns (including
+// {wildcards}, read with r.PathValue) and then by method. Unlike registering
+// "METHOD /path" patterns directly, a known path with the wrong method gets a
+// problem+json 405 with an Allow header even though a catch-all is installed.
+type Router struct {
+	mux    *http.ServeMux
+	routes map[string]*route
+
+	// Legacy describes the deprecation announced on legacy aliases
+	Legacy LegacyPolicy
+}
+
+// route is every method registered for one path pattern
+type route struct {
+	pattern  string
+	handlers map[string]http.HandlerFunc
+}
+
+func New() *Router {
+	rt := &Router{mux: http.NewServeMux(), routes: map[string]*route{}}
+	rt.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		utils.SendError(w, http.StatusNotFound, "Invalid endpoint: "+r.URL.Path)
+	})
+	return rt
+}
+
+// Handle registers handler for method on the path pattern, outside any API
+// version (for fixed, standard paths such as /.well-known/jwks.json)
+func (rt *Router) Handle(method, pattern string, handler http.HandlerFunc) {
+	rr, ok := rt.routes[pattern]
+	if !ok {
+		rr = &route{pattern: pattern, handlers: map[string]http.HandlerFunc{}}
+		rt.routes[pattern] = rr
+		rt.mux.Handle(pattern, rr)
+	}
+	if _, dup := rr.handlers[method]; dup {
+		panic("router: duplicate route " + method + " " + pattern)
+	}
+	rr.handlers[method] = handler
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.mux.ServeHTTP(w, r)
+}
+
+// allow lists the methods the route answers, for the Allow header
+func (rr *route) allow() string {
+	methods := []string{http.MethodOptions}
+	for m := range rr.handlers {
+		methods = append(methods, m)
+	}
+	if _, ok := rr.handlers[http.MethodGet]; ok {
+		if _, ok := rr.handlers[http.MethodHead]; !ok {
+			methods = append(methods, http.MethodHead)
+		}
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
+
+func (rr *route) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	handler, ok := rr.handlers[r.Method]
+	if !ok && r.Method == http.MethodHead {
+		handler, ok = rr.handlers[http.MethodGet]
+	}
+	if ok {
+		handler(w, r)
+		return
+	}
+
+	w.Header().Set("Allow", rr.allow())
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	utils.SendError(w, http.StatusMethodNotAllowed, "Method "+r.Method+" is not allowed on "+r.URL.Path)
+}
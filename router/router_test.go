@@ -0,0 +1,132 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func testRouter() *Router {
+	rt := New()
+	rt.Legacy = LegacyPolicy{
+		DeprecatedAt: time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC),
+		Sunset:       time.Date(2027, 4, 17, 0, 0, 0, 0, time.UTC),
+	}
+	reply := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body + r.PathValue("id")))
+		}
+	}
+
+	v1 := rt.Version("/v1")
+	v1.Handle("GET", "/items", nil, reply("list"))
+	v1.Handle("POST", "/items", nil, reply("create"))
+	v1.Handle("GET", "/items/{id}", nil, reply("get "))
+	v1.Handle("POST", "/sessions", nil, reply("login"))
+
+	v1.Legacy("GET", "/getitems", "GET", "/items")
+	v1.Legacy("POST", "/getitems", "GET", "/items")
+	v1.Legacy("GET", "/item", "GET", "/items/{id}")
+	v1.LegacyGone("GET", "/login", "POST", "/sessions", "use POST /v1/sessions")
+	return rt
+}
+
+func TestRouterDispatch(t *testing.T) {
+	tests := []struct {
+		method, path string
+		status       int
+		body         string
+		allow        string
+	}{
+		{"GET", "/v1/items", http.StatusOK, "list", ""},
+		{"POST", "/v1/items", http.StatusOK, "create", ""},
+		{"GET", "/v1/items/42", http.StatusOK, "get 42", ""},
+		{"HEAD", "/v1/items", http.StatusOK, "", ""},
+		{"DELETE", "/v1/items", http.StatusMethodNotAllowed, "", "GET, HEAD, OPTIONS, POST"},
+		{"OPTIONS", "/v1/items", http.StatusNoContent, "", "GET, HEAD, OPTIONS, POST"},
+		{"PUT", "/v1/items/42", http.StatusMethodNotAllowed, "", "GET, HEAD, OPTIONS"},
+		{"GET", "/v1/nothing", http.StatusNotFound, "", ""},
+		{"GET", "/getitems", http.StatusOK, "list", ""},
+		{"POST", "/getitems", http.StatusOK, "list", ""},
+		{"GET", "/login", http.StatusGone, "", ""},
+	}
+	rt := testRouter()
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.body)
+			}
+			if got := w.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("Allow = %q, want %q", got, tt.allow)
+			}
+		})
+	}
+}
+
+func TestLegacyHeaders(t *testing.T) {
+	tests := []struct {
+		method, path string
+		link         string
+	}{
+		{"GET", "/getitems", `</v1/items>; rel="successor-version"`},
+		// Successors with wildcards cannot be linked
+		{"GET", "/item", ""},
+		{"GET", "/login", `</v1/sessions>; rel="successor-version"`},
+	}
+	rt := testRouter()
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if got := w.Header().Get("Deprecation"); got != "@1792195200" {
+				t.Errorf("Deprecation = %q", got)
+			}
+			if got := w.Header().Get("Sunset"); got != "Sat, 17 Apr 2027 00:00:00 GMT" {
+				t.Errorf("Sunset = %q", got)
+			}
+			if got := w.Header().Get("Link"); got != tt.link {
+				t.Errorf("Link = %q, want %q", got, tt.link)
+			}
+		})
+	}
+
+	// Versioned paths are not deprecated
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest("GET", "/v1/items", nil))
+	if w.Header().Get("Deprecation") != "" {
+		t.Error("versioned path announces a deprecation")
+	}
+}
+
+func TestNextVersionReplace(t *testing.T) {
+	rt := testRouter()
+	v1 := rt.Version("/v1")
+	v1.Handle("GET", "/things", nil, func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("v1")) })
+	v2 := rt.NextVersion("/v2", v1)
+	v2.Replace("GET", "/things", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("v2")) })
+
+	for path, want := range map[string]string{"/v1/things": "v1", "/v2/things": "v2"} {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Body.String() != want {
+			t.Errorf("GET %s = %q, want %q", path, w.Body.String(), want)
+		}
+	}
+}
+
+func TestDuplicateRoutePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a route twice did not panic")
+		}
+	}()
+	rt := New()
+	rt.Handle("GET", "/x", func(http.ResponseWriter, *http.Request) {})
+	rt.Handle("GET", "/x", func(http.ResponseWriter, *http.Request) {})
+}
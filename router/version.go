@@ -0,0 +1,120 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"trello-lite/utils"
+)
+
+// Version is one API version: every endpoint registered on it is served
+// under its prefix, e.g. "/v1". Endpoints remember their middleware so a
+// newer version can inherit them and swap only the handlers that changed.
+type Version struct {
+	rt        *Router
+	prefix    string
+	endpoints map[string]*endpoint
+	order     []string
+}
+
+type endpoint struct {
+	method     string
+	path       string
+	middleware Middleware
+	handler    http.HandlerFunc
+}
+
+func endpointKey(method, path string) string {
+	return method + " " + path
+}
+
+// Version starts an empty API version served under prefix
+func (rt *Router) Version(prefix string) *Version {
+	return &Version{rt: rt, prefix: prefix, endpoints: map[string]*endpoint{}}
+}
+
+// NextVersion starts a version under prefix that serves every endpoint of
+// base, with the same middleware. Use Replace to give an endpoint a new handler.
+func (rt *Router) NextVersion(prefix string, base *Version) *Version {
+	v := rt.Version(prefix)
+	for _, key := range base.order {
+		e := base.endpoints[key]
+		v.Handle(e.method, e.path, e.middleware, e.handler)
+	}
+	return v
+}
+
+// Handle registers an endpoint of this version. middleware may be nil.
+func (v *Version) Handle(method, path string, middleware Middleware, handler http.HandlerFunc) {
+	if middleware == nil {
+		middleware = func(h http.HandlerFunc) http.HandlerFunc { return h }
+	}
+	e := &endpoint{method: method, path: path, middleware: middleware, handler: handler}
+	v.endpoints[endpointKey(method, path)] = e
+	v.order = append(v.order, endpointKey(method, path))
+	v.rt.Handle(method, v.prefix+path, middleware(handler))
+}
+
+// Replace swaps the handler of an existing endpoint, keeping its middleware.
+// This is how a version changes one endpoint of the version it came from.
+func (v *Version) Replace(method, path string, handler http.HandlerFunc) {
+	e := v.lookup(method, path)
+	e.handler = handler
+	v.rt.routes[v.prefix+path].handlers[method] = e.middleware(handler)
+}
+
+// Legacy serves an endpoint of this version on one of the original,
+// unversioned paths. Responses announce the deprecation and sunset date and,
+// where the successor path has no wildcards, link to it.
+func (v *Version) Legacy(legacyMethod, legacyPath, method, path string) {
+	e := v.lookup(method, path)
+	successor := ""
+	if !strings.Contains(path, "{") {
+		successor = v.prefix + path
+	}
+	v.rt.Handle(legacyMethod, legacyPath, v.rt.Legacy.wrap(successor, e.middleware(e.handler)))
+}
+
+// LegacyGone answers a legacy path that can no longer be served as it was
+// with 410 Gone and a message on how to migrate to method path
+func (v *Version) LegacyGone(legacyMethod, legacyPath, method, path, message string) {
+	v.lookup(method, path)
+	successor := ""
+	if !strings.Contains(path, "{") {
+		successor = v.prefix + path
+	}
+	v.rt.Handle(legacyMethod, legacyPath, v.rt.Legacy.wrap(successor, func(w http.ResponseWriter, r *http.Request) {
+		utils.SendError(w, http.StatusGone, message)
+	}))
+}
+
+func (v *Version) lookup(method, path string) *endpoint {
+	e, ok := v.endpoints[endpointKey(method, path)]
+	if !ok {
+		panic("router: no endpoint " + method + " " + v.prefix + path)
+	}
+	return e
+}
+
+// LegacyPolicy is the deprecation announced on legacy paths, using the
+// Deprecation (RFC 9745) and Sunset (RFC 8594) response headers
+type LegacyPolicy struct {
+	DeprecatedAt time.Time
+	Sunset       time.Time
+}
+
+func (p LegacyPolicy) wrap(successor string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !p.DeprecatedAt.IsZero() {
+			w.Header().Set("Deprecation", "@"+strconv.FormatInt(p.DeprecatedAt.Unix(), 10))
+		}
+		if !p.Sunset.IsZero() {
+			w.Header().Set("Sunset", p.Sunset.UTC().Format(http.TimeFormat))
+		}
+		if successor != "" {
+			w.Header().Add("Link", "<"+successor+`>; rel="successor-version"`)
+		}
+		next(w, r)
+	}
+}
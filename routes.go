@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+	"trello-lite/handlers"
+	"trello-lite/middleware"
+	"trello-lite/models"
+	"trello-lite/router"
+)
+
+// legacyDeprecatedAt is when the unversioned paths were deprecated in favour of /v1
+var legacyDeprecatedAt = time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+
+// legacySunset is when the unversioned paths stop working, unless
+// LEGACY_API_SUNSET (YYYY-MM-DD) says otherwise
+var legacySunset = legacyDeprecatedAt.AddDate(0, 6, 0)
+
+// Middleware chains used by the route table. nil means public.
+func scope(s string) router.Middleware {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return middleware.AuthMiddleware(middleware.RequireScope(s, h))
+	}
+}
+
+func session(h http.HandlerFunc) http.HandlerFunc {
+	return middleware.AuthMiddleware(middleware.SessionOnly(h))
+}
+
+// legacyPolicy reads the sunset date announced on legacy paths
+func legacyPolicy() (router.LegacyPolicy, error) {
+	policy := router.LegacyPolicy{DeprecatedAt: legacyDeprecatedAt, Sunset: legacySunset}
+	if s := os.Getenv("LEGACY_API_SUNSET"); s != "" {
+		sunset, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return policy, fmt.Errorf("LEGACY_API_SUNSET must be a YYYY-MM-DD date: %w", err)
+		}
+		policy.Sunset = sunset
+	}
+	return policy, nil
+}
+
+// registerRoutes builds the versioned API. A later version starts from the
+// one before with rt.NextVersion and swaps individual handlers with Replace,
+// so authentication and scopes are declared once per endpoint:
+//
+//	v2 := rt.NextVersion("/v2", v1)
+//	v2.Replace("GET", "/projects", handlers.ListProjectsV2Handler)
+func registerRoutes(rt *router.Router) {
+	rt.Handle("GET", "/.well-known/jwks.json", handlers.JWKSHandler)
+
+	v1 := rt.Version("/v1")
+	registerV1(v1)
+	registerLegacyRoutes(v1)
+}
+
+// registerV1 is the resource-oriented route table of /v1
+func registerV1(v1 *router.Version) {
+	// 1. Accounts and sessions
+	v1.Handle("POST", "/users", nil, handlers.SignupHandler)
+	v1.Handle("GET", "/users", scope(models.ScopeUsersRead), handlers.GetAllUsersHandler)
+	v1.Handle("PUT", "/users/{userId}/role", session, handlers.UpdateUserRoleHandler)
+	v1.Handle("PUT", "/me/password", session, handlers.ChangePasswordHandler)
+	v1.Handle("POST", "/sessions", nil, handlers.LoginHandler)
+	v1.Handle("POST", "/sessions/refresh", nil, handlers.RefreshTokenHandler)
+	v1.Handle("DELETE", "/sessions/current", session, handlers.LogoutHandler)
+	v1.Handle("DELETE", "/sessions", session, handlers.LogoutAllHandler)
+	v1.Handle("POST", "/service-accounts", session, handlers.CreateServiceAccountHandler)
+	v1.Handle("GET", "/api-keys", session, handlers.ListAPIKeysHandler)
+	v1.Handle("POST", "/api-keys", session, handlers.CreateAPIKeyHandler)
+	v1.Handle("DELETE", "/api-keys/{keyId}", session, handlers.RevokeAPIKeyHandler)
+
+	// 2. Projects
+	v1.Handle("GET", "/projects", scope(models.ScopeProjectsRead), handlers.GetMyProjectsHandler)
+	v1.Handle("POST", "/projects", scope(models.ScopeProjectsWrite), handlers.CreateProjectHandler)
+	v1.Handle("GET", "/projects/{projectId}", scope(models.ScopeProjectsRead), handlers.GetProjectHandler)
+	v1.Handle("PATCH", "/projects/{projectId}", scope(models.ScopeProjectsWrite), handlers.UpdateProjectHandler)
+	v1.Handle("DELETE", "/projects/{projectId}", session, handlers.DeleteProjectHandler)
+	v1.Handle("POST", "/projects/{projectId}/archive", scope(models.ScopeProjectsWrite), handlers.ArchiveProjectHandler)
+	v1.Handle("POST", "/projects/{projectId}/restore", scope(models.ScopeProjectsWrite), handlers.RestoreProjectHandler)
+	v1.Handle("PUT", "/projects/{projectId}/columns", scope(models.ScopeProjectsWrite), handlers.UpdateColumnsHandler)
+	v1.Handle("PUT", "/projects/{projectId}/workflow", scope(models.ScopeProjectsWrite), handlers.UpdateWorkflowHandler)
+	v1.Handle("PUT", "/projects/{projectId}/wip-limits", scope(models.ScopeProjectsWrite), handlers.UpdateWIPLimitsHandler)
+	v1.Handle("POST", "/projects/{projectId}/transfer", session, handlers.TransferProjectOwnershipHandler)
+	v1.Handle("GET", "/projects/{projectId}/members", scope(models.ScopeProjectsRead), handlers.ListProjectMembersHandler)
+	v1.Handle("POST", "/projects/{projectId}/members", scope(models.ScopeProjectsWrite), handlers.AddProjectMemberHandler)
+	v1.Handle("DELETE", "/projects/{projectId}/members/{userId}", scope(models.ScopeProjectsWrite), handlers.RemoveProjectMemberHandler)
+	v1.Handle("PUT", "/projects/{projectId}/members/{userId}/role", scope(models.ScopeProjectsWrite), handlers.UpdateProjectMemberRoleHandler)
+	v1.Handle("GET", "/projects/{projectId}/invitations", scope(models.ScopeProjectsRead), handlers.ListInvitationsHandler)
+	v1.Handle("POST", "/projects/{projectId}/invitations", scope(models.ScopeProjectsWrite), handlers.CreateInvitationHandler)
+	v1.Handle("DELETE", "/projects/{projectId}/invitations/{invitationId}", scope(models.ScopeProjectsWrite), handlers.RevokeInvitationHandler)
+	v1.Handle("GET", "/projects/{projectId}/labels", scope(models.ScopeProjectsRead), handlers.ListLabelsHandler)
+	v1.Handle("POST", "/projects/{projectId}/labels", scope(models.ScopeProjectsWrite), handlers.CreateLabelHandler)
+	v1.Handle("PATCH", "/projects/{projectId}/labels/{labelId}", scope(models.ScopeProjectsWrite), handlers.UpdateLabelHandler)
+	v1.Handle("DELETE", "/projects/{projectId}/labels/{labelId}", scope(models.ScopeProjectsWrite), handlers.DeleteLabelHandler)
+	v1.Handle("GET", "/projects/{projectId}/fields", scope(models.ScopeProjectsRead), handlers.ListCustomFieldsHandler)
+	v1.Handle("POST", "/projects/{projectId}/fields", scope(models.ScopeProjectsWrite), handlers.CreateCustomFieldHandler)
+	v1.Handle("PATCH", "/projects/{projectId}/fields/{fieldId}", scope(models.ScopeProjectsWrite), handlers.UpdateCustomFieldHandler)
+	v1.Handle("DELETE", "/projects/{projectId}/fields/{fieldId}", scope(models.ScopeProjectsWrite), handlers.DeleteCustomFieldHandler)
+	v1.Handle("POST", "/invitations/accept", session, handlers.AcceptInvitationHandler)
+	v1.Handle("POST", "/invitations/decline", nil, handlers.DeclineInvitationHandler)
+
+	// 3. Tasks
+	v1.Handle("GET", "/projects/{projectId}/tasks", scope(models.ScopeTasksRead), handlers.GetTasksByProjectHandler)
+	v1.Handle("POST", "/projects/{projectId}/tasks", scope(models.ScopeTasksWrite), handlers.CreateTaskHandler)
+	v1.Handle("GET", "/projects/{projectId}/tasks/export", scope(models.ScopeTasksRead), handlers.ExportTasksHandler)
+	v1.Handle("GET", "/projects/{projectId}/tasks/{taskId}", scope(models.ScopeTasksRead), handlers.GetTaskHandler)
+	v1.Handle("PATCH", "/projects/{projectId}/tasks/{taskId}", scope(models.ScopeTasksWrite), handlers.PatchTaskHandler)
+	v1.Handle("DELETE", "/projects/{projectId}/tasks/{taskId}", scope(models.ScopeTasksWrite), handlers.DeleteTaskHandler)
+	v1.Handle("PUT", "/projects/{projectId}/tasks/{taskId}/status", scope(models.ScopeTasksWrite), handlers.UpdateTaskStatusHandler)
+	v1.Handle("PUT", "/projects/{projectId}/tasks/{taskId}/assignee", scope(models.ScopeTasksWrite), handlers.UpdateTaskownerHandler)
+	v1.Handle("POST", "/projects/{projectId}/tasks/{taskId}/move", scope(models.ScopeTasksWrite), handlers.MoveTaskHandler)
+	v1.Handle("GET", "/projects/{projectId}/tasks/{taskId}/subtasks", scope(models.ScopeTasksRead), handlers.ListSubtasksHandler)
+	v1.Handle("POST", "/projects/{projectId}/tasks/{taskId}/checklist", scope(models.ScopeTasksWrite), handlers.AddChecklistItemHandler)
+	v1.Handle("PUT", "/projects/{projectId}/tasks/{taskId}/checklist/order", scope(models.ScopeTasksWrite), handlers.ReorderChecklistHandler)
+	v1.Handle("PATCH", "/projects/{projectId}/tasks/{taskId}/checklist/{itemId}", scope(models.ScopeTasksWrite), handlers.UpdateChecklistItemHandler)
+	v1.Handle("DELETE", "/projects/{projectId}/tasks/{taskId}/checklist/{itemId}", scope(models.ScopeTasksWrite), handlers.DeleteChecklistItemHandler)
+	v1.Handle("GET", "/projects/{projectId}/tasks/{taskId}/links", scope(models.ScopeTasksRead), handlers.ListLinksHandler)
+	v1.Handle("POST", "/projects/{projectId}/tasks/{taskId}/links", scope(models.ScopeTasksWrite), handlers.CreateLinkHandler)
+	v1.Handle("DELETE", "/projects/{projectId}/tasks/{taskId}/links/{linkId}", scope(models.ScopeTasksWrite), handlers.DeleteLinkHandler)
+	v1.Handle("GET", "/projects/{projectId}/tasks/{taskId}/graph", scope(models.ScopeTasksRead), handlers.GetDependencyGraphHandler)
+	v1.Handle("GET", "/tasks/search", scope(models.ScopeTasksRead), handlers.SearchTaskHandler)
+
+	// 4. Comments
+	v1.Handle("GET", "/projects/{projectId}/tasks/{taskId}/comments", scope(models.ScopeTasksRead), handlers.ListCommentsHandler)
+	v1.Handle("POST", "/projects/{projectId}/tasks/{taskId}/comments", scope(models.ScopeTasksWrite), handlers.CreateCommentHandler)
+	v1.Handle("PATCH", "/projects/{projectId}/tasks/{taskId}/comments/{commentId}", scope(models.ScopeTasksWrite), handlers.EditCommentHandler)
+	v1.Handle("DELETE", "/projects/{projectId}/tasks/{taskId}/comments/{commentId}", scope(models.ScopeTasksWrite), handlers.DeleteCommentHandler)
+	v1.Handle("GET", "/projects/{projectId}/tasks/{taskId}/comments/{commentId}/history", scope(models.ScopeTasksRead), handlers.CommentHistoryHandler)
+
+	// 5. Instance administration
+	v1.Handle("GET", "/admin/everything", session, handlers.GetEverythingAggregateHandler)
+}
+
+// registerLegacyRoutes keeps the original, unversioned paths working with
+// the methods they were documented with. Each one serves the /v1 endpoint it
+// names and answers with Deprecation and Sunset headers.
+// Deprecated: do not add new routes here.
+func registerLegacyRoutes(v1 *router.Version) {
+	v1.Legacy("POST", "/signup", "POST", "/users")
+	v1.Legacy("POST", "/login", "POST", "/sessions")
+	// The original login took credentials in the query string, where they
+	// end up in access logs; it is refused rather than translated
+	v1.LegacyGone("GET", "/login", "POST", "/sessions",
+		"GET /login with credentials in the query string is no longer supported; POST {\"email\", \"password\"} to /v1/sessions")
+	v1.Legacy("POST", "/token/refresh", "POST", "/sessions/refresh")
+	v1.Legacy("POST", "/logout", "DELETE", "/sessions/current")
+	v1.Legacy("POST", "/logout-all", "DELETE", "/sessions")
+	v1.Legacy("POST", "/password/change", "PUT", "/me/password")
+	v1.Legacy("POST", "/user/role", "PUT", "/users/{userId}/role")
+	v1.Legacy("GET", "/getallusers", "GET", "/users")
+	v1.Legacy("POST", "/getallusers", "GET", "/users")
+	v1.Legacy("POST", "/serviceaccount/create", "POST", "/service-accounts")
+	v1.Legacy("POST", "/apikey/create", "POST", "/api-keys")
+	v1.Legacy("GET", "/apikey/list", "GET", "/api-keys")
+	v1.Legacy("POST", "/apikey/revoke", "DELETE", "/api-keys/{keyId}")
+
+	v1.Legacy("POST", "/project/create", "POST", "/projects")
+	v1.Legacy("GET", "/getProject", "GET", "/projects")
+	v1.Legacy("POST", "/project/update", "PATCH", "/projects/{projectId}")
+	v1.Legacy("POST", "/project/archive", "POST", "/projects/{projectId}/archive")
+	v1.Legacy("POST", "/project/restore", "POST", "/projects/{projectId}/restore")
+	v1.Legacy("POST", "/project/delete", "DELETE", "/projects/{projectId}")
+	v1.Legacy("DELETE", "/project/delete", "DELETE", "/projects/{projectId}")
+	v1.Legacy("POST", "/project/columns", "PUT", "/projects/{projectId}/columns")
+	v1.Legacy("POST", "/project/workflow", "PUT", "/projects/{projectId}/workflow")
+	v1.Legacy("POST", "/project/wip", "PUT", "/projects/{projectId}/wip-limits")
+	v1.Legacy("GET", "/project/members", "GET", "/projects/{projectId}/members")
+	v1.Legacy("POST", "/project/member/add", "POST", "/projects/{projectId}/members")
+	v1.Legacy("POST", "/project/member/remove", "DELETE", "/projects/{projectId}/members/{userId}")
+	v1.Legacy("POST", "/project/member/role", "PUT", "/projects/{projectId}/members/{userId}/role")
+	v1.Legacy("POST", "/project/transfer", "POST", "/projects/{projectId}/transfer")
+	v1.Legacy("POST", "/project/invite", "POST", "/projects/{projectId}/invitations")
+	v1.Legacy("GET", "/project/invitations", "GET", "/projects/{projectId}/invitations")
+	v1.Legacy("POST", "/invitation/revoke", "DELETE", "/projects/{projectId}/invitations/{invitationId}")
+	v1.Legacy("POST", "/invitation/accept", "POST", "/invitations/accept")
+	v1.Legacy("POST", "/invitation/decline", "POST", "/invitations/decline")
+
+	v1.Legacy("POST", "/task/create", "POST", "/projects/{projectId}/tasks")
+	v1.Legacy("GET", "/task", "GET", "/projects/{projectId}/tasks/{taskId}")
+	v1.Legacy("PATCH", "/task", "PATCH", "/projects/{projectId}/tasks/{taskId}")
+	v1.Legacy("GET", "/tasks", "GET", "/projects/{projectId}/tasks")
+	v1.Legacy("POST", "/task/update", "PUT", "/projects/{projectId}/tasks/{taskId}/status")
+	v1.Legacy("POST", "/task/move", "POST", "/projects/{projectId}/tasks/{taskId}/move")
+	v1.Legacy("DELETE", "/task/delete", "DELETE", "/projects/{projectId}/tasks/{taskId}")
+	v1.Legacy("GET", "/task/search", "GET", "/tasks/search")
+	v1.Legacy("POST", "/taskOwnerUpdate", "PUT", "/projects/{projectId}/tasks/{taskId}/assignee")
+	v1.Legacy("GET", "/everything", "GET", "/admin/everything")
+}
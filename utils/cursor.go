@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// cursorKey signs the paging cursors handed to clients, so a cursor that
+// comes back is known to be one the server made
+var cursorKey []byte
+
+// LoadCursorKey reads the cursor signing secret from CURSOR_SECRET or
+// CURSOR_SECRET_FILE. With neither set a random key is generated, so cursors
+// stop working after a restart and clients start again from the first page.
+func LoadCursorKey() error {
+	secret := os.Getenv("CURSOR_SECRET")
+	if path := os.Getenv("CURSOR_SECRET_FILE"); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading CURSOR_SECRET_FILE: %w", err)
+		}
+		secret = strings.TrimSpace(string(raw))
+	}
+
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return err
+		}
+		cursorKey = buf
+		return nil
+	}
+	if len(secret) < 32 {
+		return errors.New("CURSOR_SECRET must be at least 32 bytes")
+	}
+	cursorKey = []byte(secret)
+	return nil
+}
+
+// SignCursor encodes data as an opaque, signed cursor: base64(data).base64(mac)
+func SignCursor(data []byte) (string, error) {
+	if cursorKey == nil {
+		return "", errors.New("cursor key not loaded")
+	}
+	return base64.RawURLEncoding.EncodeToString(data) + "." +
+		base64.RawURLEncoding.EncodeToString(cursorMAC(data)), nil
+}
+
+// VerifyCursor checks a cursor's signature and returns the data it carries
+func VerifyCursor(cursor string) ([]byte, error) {
+	encoded, sig, ok := strings.Cut(cursor, ".")
+	if !ok || cursorKey == nil {
+		return nil, errors.New("malformed cursor")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(mac, cursorMAC(data)) {
+		return nil, errors.New("cursor signature does not match")
+	}
+	return data, nil
+}
+
+func cursorMAC(data []byte) []byte {
+	h := hmac.New(sha256.New, cursorKey)
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// inviteAudience keeps invitation tokens and access tokens from being used in place of each other
+const inviteAudience = "trello-lite-invite"
+
+type InviteClaims struct {
+	InvitationID string `json:"inv"`
+	Email        string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// GenerateInviteToken signs a token naming an invitation, valid until expiresAt
+func GenerateInviteToken(invitationID, email string, expiresAt time.Time) (string, error) {
+	claims := &InviteClaims{
+		InvitationID: invitationID,
+		Email:        email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "trello-lite",
+			Audience:  jwt.ClaimStrings{inviteAudience},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	return SignToken(claims)
+}
+
+// ParseInviteToken verifies an invitation token and returns its claims
+func ParseInviteToken(tokenStr string) (*InviteClaims, error) {
+	claims := &InviteClaims{}
+	_, err := jwt.ParseWithClaims(tokenStr, claims, VerifyKey,
+		jwt.WithValidMethods(ValidMethods()),
+		jwt.WithExpirationRequired(),
+		jwt.WithAudience(inviteAudience),
+	)
+	return claims, err
+}
@@ -1,30 +1,58 @@
 package utils
 
 import (
+	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var JwtKey = []byte()
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
 
 type Claims struct {
-	UserID string `json:"user_id"`
-	Role   string `json:"role"`
+	UserID  string `json:"user_id"`
+	Role    string `json:"role"`
+	Version int    `json:"ver"`
 	jwt.RegisteredClaims
 }
 
-// GenerateJWT creates a token for a logged-in user
-func GenerateJWT(userID, role string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+// GenerateJWT creates a short-lived access token for a logged-in user.
+// version must match the user's current token version for the token to be accepted.
+func GenerateJWT(userID, role string, version int) (string, error) {
+	jti, err := NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
 	claims := &Claims{
-		UserID: userID,
-		Role:   role,
+		UserID:  userID,
+		Role:    role,
+		Version: version,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			ID:        jti,
+			Issuer:    "trello-lite",
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(JwtKey)
+	return SignToken(claims)
+}
+
+// ParseJWT verifies an access token against the key ring and returns its claims
+func ParseJWT(tokenStr string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenStr, claims, VerifyKey,
+		jwt.WithValidMethods(ValidMethods()),
+		jwt.WithExpirationRequired(),
+	)
+	if err == nil && claims.UserID == "" {
+		// Other tokens we sign (such as invitations) share the keys but name no user
+		err = errors.New("not an access token")
+	}
+	return claims, err
 }
@@ -0,0 +1,275 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is one entry of the key ring. Keys without a private half can
+// only verify, which is how retired keys are kept around during rotation.
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// keyRing holds every key tokens may be verified with; active is the one new tokens are signed with
+var keyRing = struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}{keys: map[string]*SigningKey{}}
+
+// keyFileEntry is one key in the JWT_KEYS_FILE document
+type keyFileEntry struct {
+	KID            string `json:"kid"`
+	Alg            string `json:"alg"`
+	Secret         string `json:"secret"`
+	SecretFile     string `json:"secretFile"`
+	PrivateKeyFile string `json:"privateKeyFile"`
+	PublicKeyFile  string `json:"publicKeyFile"`
+}
+
+// LoadSigningKeys configures the key ring from the environment:
+//
+//   - JWT_KEYS_FILE: JSON {"active": "<kid>", "keys": [...]} allowing several
+//     HS256/RS256/EdDSA keys at once for rotation
+//   - JWT_SECRET or JWT_SECRET_FILE: a single HS256 secret (kid from JWT_KEY_ID, default "default")
+//
+// With neither set a random key is generated, so tokens do not survive a restart.
+func LoadSigningKeys() error {
+	keyRing.active = nil
+	keyRing.keys = map[string]*SigningKey{}
+
+	if path := os.Getenv("JWT_KEYS_FILE"); path != "" {
+		return loadKeysFile(path)
+	}
+
+	secret := os.Getenv("JWT_SECRET")
+	if path := os.Getenv("JWT_SECRET_FILE"); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading JWT_SECRET_FILE: %w", err)
+		}
+		secret = strings.TrimSpace(string(raw))
+	}
+
+	if secret == "" {
+		fmt.Println("WARNING: no JWT_SECRET or JWT_KEYS_FILE configured, using a random key; tokens will not survive a restart")
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return err
+		}
+		secret = string(buf)
+	}
+
+	kid := os.Getenv("JWT_KEY_ID")
+	if kid == "" {
+		kid = "default"
+	}
+	key, err := buildKey(keyFileEntry{KID: kid, Alg: "HS256", Secret: secret})
+	if err != nil {
+		return err
+	}
+	keyRing.keys[kid] = key
+	keyRing.active = key
+	return nil
+}
+
+func loadKeysFile(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading JWT_KEYS_FILE: %w", err)
+	}
+
+	var doc struct {
+		Active string         `json:"active"`
+		Keys   []keyFileEntry `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return fmt.Errorf("parsing JWT_KEYS_FILE: %w", err)
+	}
+
+	for _, entry := range doc.Keys {
+		if entry.KID == "" {
+			return errors.New("every key in JWT_KEYS_FILE needs a kid")
+		}
+		if _, dup := keyRing.keys[entry.KID]; dup {
+			return fmt.Errorf("duplicate kid %q in JWT_KEYS_FILE", entry.KID)
+		}
+		key, err := buildKey(entry)
+		if err != nil {
+			return fmt.Errorf("key %q: %w", entry.KID, err)
+		}
+		keyRing.keys[entry.KID] = key
+	}
+
+	active, ok := keyRing.keys[doc.Active]
+	if !ok {
+		return fmt.Errorf("active kid %q not found in JWT_KEYS_FILE", doc.Active)
+	}
+	if active.signKey == nil {
+		return fmt.Errorf("active key %q has no private key", doc.Active)
+	}
+	keyRing.active = active
+	return nil
+}
+
+func buildKey(entry keyFileEntry) (*SigningKey, error) {
+	key := &SigningKey{ID: entry.KID}
+
+	switch entry.Alg {
+	case "HS256":
+		secret := entry.Secret
+		if entry.SecretFile != "" {
+			raw, err := os.ReadFile(entry.SecretFile)
+			if err != nil {
+				return nil, err
+			}
+			secret = strings.TrimSpace(string(raw))
+		}
+		if len(secret) < 32 {
+			return nil, errors.New("HS256 secrets must be at least 32 bytes")
+		}
+		key.Method = jwt.SigningMethodHS256
+		key.signKey = []byte(secret)
+		key.verifyKey = []byte(secret)
+
+	case "RS256":
+		key.Method = jwt.SigningMethodRS256
+		if entry.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(entry.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			private, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			key.signKey = private
+			key.verifyKey = &private.PublicKey
+		} else if entry.PublicKeyFile != "" {
+			pem, err := os.ReadFile(entry.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			if key.verifyKey, err = jwt.ParseRSAPublicKeyFromPEM(pem); err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, errors.New("RS256 keys need privateKeyFile or publicKeyFile")
+		}
+
+	case "EdDSA":
+		key.Method = jwt.SigningMethodEdDSA
+		if entry.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(entry.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			private, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			key.signKey = private
+			key.verifyKey = private.(crypto.Signer).Public()
+		} else if entry.PublicKeyFile != "" {
+			pem, err := os.ReadFile(entry.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			if key.verifyKey, err = jwt.ParseEdPublicKeyFromPEM(pem); err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, errors.New("EdDSA keys need privateKeyFile or publicKeyFile")
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported alg %q (use HS256, RS256 or EdDSA)", entry.Alg)
+	}
+
+	return key, nil
+}
+
+// SignToken signs claims with the active key and stamps its kid in the header
+func SignToken(claims jwt.Claims) (string, error) {
+	active := keyRing.active
+	if active == nil {
+		return "", errors.New("signing keys not loaded")
+	}
+
+	token := jwt.NewWithClaims(active.Method, claims)
+	token.Header["kid"] = active.ID
+	return token.SignedString(active.signKey)
+}
+
+// VerifyKey is the jwt.Keyfunc for tokens issued by this service. The key is
+// chosen by kid and must use the same algorithm the token claims, so an
+// attacker cannot downgrade an RS256 key to HS256 with the public key as secret.
+func VerifyKey(t *jwt.Token) (interface{}, error) {
+	key := keyRing.active
+	if kid, ok := t.Header["kid"].(string); ok {
+		key = keyRing.keys[kid]
+	}
+	if key == nil {
+		return nil, errors.New("unknown signing key")
+	}
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.verifyKey, nil
+}
+
+// ValidMethods lists the algorithms of every configured key, for jwt.WithValidMethods
+func ValidMethods() []string {
+	seen := map[string]bool{}
+	methods := []string{}
+	for _, key := range keyRing.keys {
+		if !seen[key.Method.Alg()] {
+			seen[key.Method.Alg()] = true
+			methods = append(methods, key.Method.Alg())
+		}
+	}
+	return methods
+}
+
+// JWKS returns the public half of every asymmetric key as a JSON Web Key Set.
+// HMAC secrets are never published.
+func JWKS() map[string]interface{} {
+	keys := []map[string]string{}
+	for _, key := range keyRing.keys {
+		switch public := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, map[string]string{
+				"kty": "RSA",
+				"kid": key.ID,
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			keys = append(keys, map[string]string{
+				"kty": "OKP",
+				"crv": "Ed25519",
+				"kid": key.ID,
+				"alg": "EdDSA",
+				"use": "sig",
+				"x":   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+	return map[string]interface{}{"keys": keys}
+}
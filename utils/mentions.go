@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// Fenced code blocks and inline code spans are not prose, so an @ in them is no mention
+	markdownFence = regexp.MustCompile("(?s)```.*?(```|$)|~~~.*?(~~~|$)")
+	markdownCode  = regexp.MustCompile("`[^`\n]*`")
+
+	// @handle or @full@email.address, not preceded by a word character (so
+	// plain email addresses in the text are not mentions)
+	mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([\w.+-]+(?:@[\w-]+(?:\.[\w-]+)+)?)`)
+)
+
+// ParseMentions returns the distinct @handles in a Markdown body, lower-cased
+// and in order of appearance, ignoring code
+func ParseMentions(markdown string) []string {
+	text := markdownFence.ReplaceAllString(markdown, " ")
+	text = markdownCode.ReplaceAllString(text, " ")
+
+	seen := map[string]bool{}
+	var handles []string
+	for _, m := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		// A sentence may end right after the handle: "thanks @alice."
+		handle := strings.ToLower(strings.TrimRight(m[1], ".-"))
+		if handle != "" && !seen[handle] {
+			seen[handle] = true
+			handles = append(handles, handle)
+		}
+	}
+	return handles
+}
@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		markdown string
+		want     []string
+	}{
+		{"no mentions here", nil},
+		{"@alice please look", []string{"alice"}},
+		{"thanks @Alice.", []string{"alice"}},
+		{"@bob, @alice and @bob again", []string{"bob", "alice"}},
+		{"ask @bob@example.com", []string{"bob@example.com"}},
+		{"mail bob@example.com instead", nil},
+		{"(@carol) and **@dave**", []string{"carol", "dave"}},
+		{"use `@alice` in code", nil},
+		{"```\n@alice\n```\nthen @bob", []string{"bob"}},
+		{"~~~\n@alice", nil},
+		{"@first.last-name", []string{"first.last-name"}},
+		{"just an @ sign", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.markdown, func(t *testing.T) {
+			if got := ParseMentions(tt.markdown); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMentions(%q) = %v, want %v", tt.markdown, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minPasswordLength = 8
+	// bcrypt silently ignores everything past 72 bytes, so reject it instead
+	maxPasswordLength = 72
+)
+
+// dummyHash is compared against when a login names an unknown user so the
+// response time does not reveal whether the email exists.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("trello-lite-dummy-password"), bcrypt.DefaultCost)
+
+// ValidatePassword enforces the minimal password policy for signup and password changes
+func ValidatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return errors.New("password must be at least 8 characters")
+	}
+	if len(password) > maxPasswordLength {
+		return errors.New("password must be at most 72 bytes")
+	}
+	return nil
+}
+
+// ValidatePasswordField records a password policy failure for field in errs
+func ValidatePasswordField(errs *ValidationErrors, field, password string) {
+	if len(password) < minPasswordLength {
+		errs.Add(field, CodeTooShort, field+" must be at least 8 characters")
+	} else if len(password) > maxPasswordLength {
+		errs.Add(field, CodeTooLong, field+" must be at most 72 bytes")
+	}
+}
+
+// HashPassword returns a salted bcrypt hash of the password
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches the stored hash.
+// bcrypt compares in constant time; an empty hash is checked against a dummy
+// so unknown users and users without a password cost the same.
+func CheckPassword(hash, password string) bool {
+	if hash == "" {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"errors"
+)
+
+// Ranks are base-36 fractional strings compared lexicographically, so a task
+// can always be placed between two others by rewriting only its own rank.
+const rankAlphabet = "0123456789abcdefghijklmnopqrstuvwxyz"
+const rankBase = len(rankAlphabet)
+
+// ErrInvalidRank is returned for ranks that were not made by RankBetween
+var ErrInvalidRank = errors.New("rank must be base-36 digits not ending in 0")
+
+// ValidRank reports whether rank could have come from RankBetween: lower-case
+// base-36 digits, not ending in '0'. A trailing '0' leaves no room below the
+// rank, so nothing could ever be placed between it and its prefix.
+func ValidRank(rank string) bool {
+	if rank == "" || rank[len(rank)-1] == '0' {
+		return false
+	}
+	for i := 0; i < len(rank); i++ {
+		c := rank[i]
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'z') {
+			return false
+		}
+	}
+	return true
+}
+
+func rankDigit(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'a' && c <= 'z':
+		return int(c-'a') + 10
+	}
+	return 0
+}
+
+// RankBetween returns a rank strictly between prev and next. An empty prev
+// means "before everything" and an empty next means "after everything".
+func RankBetween(prev, next string) (string, error) {
+	if (prev != "" && !ValidRank(prev)) || (next != "" && !ValidRank(next)) {
+		return "", ErrInvalidRank
+	}
+	if next != "" && prev >= next {
+		return "", errors.New("prev rank must sort before next rank")
+	}
+
+	out := []byte{}
+	upperOpen := next == ""
+	for i := 0; ; i++ {
+		lo := 0
+		if i < len(prev) {
+			lo = rankDigit(prev[i])
+		}
+		hi := rankBase
+		if !upperOpen {
+			hi = 0
+			if i < len(next) {
+				hi = rankDigit(next[i])
+			}
+		}
+
+		// Room for a digit in between: done. The midpoint is never '0', so
+		// there is always space left before the new rank too.
+		if hi-lo > 1 {
+			return string(append(out, rankAlphabet[(lo+hi)/2])), nil
+		}
+
+		out = append(out, rankAlphabet[lo])
+		if hi-lo == 1 {
+			// We are now strictly below next, so it no longer bounds later digits
+			upperOpen = true
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package utils
+
+import "testing"
+
+func TestRankBetween(t *testing.T) {
+	tests := []struct {
+		name       string
+		prev, next string
+	}{
+		{"empty column", "", ""},
+		{"at the end", "i", ""},
+		{"at the start", "", "i"},
+		{"wide gap", "a", "z"},
+		{"adjacent digits", "a", "b"},
+		{"prefix of next", "a", "a1"},
+		{"next below midpoint", "a", "a01"},
+		{"longer prev", "azz", "b"},
+		{"end after z", "z", ""},
+		{"before the smallest digit", "", "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RankBetween(tt.prev, tt.next)
+			if err != nil {
+				t.Fatalf("RankBetween(%q, %q) error: %v", tt.prev, tt.next, err)
+			}
+			if !ValidRank(got) {
+				t.Errorf("RankBetween(%q, %q) = %q, not a valid rank", tt.prev, tt.next, got)
+			}
+			if got <= tt.prev || (tt.next != "" && got >= tt.next) {
+				t.Errorf("RankBetween(%q, %q) = %q, not strictly between", tt.prev, tt.next, got)
+			}
+		})
+	}
+}
+
+func TestRankBetweenRejectsBadInput(t *testing.T) {
+	tests := []struct {
+		name       string
+		prev, next string
+	}{
+		{"equal ranks", "a", "a"},
+		{"reversed", "b", "a"},
+		{"next ends in zero", "a", "a0"},
+		{"next is only zeros", "", "00"},
+		{"prev ends in zero", "a0", "b"},
+		{"upper-case digit", "A", ""},
+		{"punctuation", "", "a-b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := RankBetween(tt.prev, tt.next); err == nil {
+				t.Errorf("RankBetween(%q, %q) = %q, want an error", tt.prev, tt.next, got)
+			}
+		})
+	}
+}
+
+// Repeatedly inserting at the same spot must keep producing valid,
+// ordered ranks
+func TestRankBetweenRepeatedInserts(t *testing.T) {
+	prev, next := "a", "b"
+	for i := 0; i < 200; i++ {
+		mid, err := RankBetween(prev, next)
+		if err != nil {
+			t.Fatalf("insert %d: %v", i, err)
+		}
+		if mid <= prev || mid >= next {
+			t.Fatalf("insert %d: %q not between %q and %q", i, mid, prev, next)
+		}
+		if i%2 == 0 {
+			next = mid
+		} else {
+			prev = mid
+		}
+	}
+}
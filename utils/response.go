@@ -5,34 +5,131 @@ import (
 	"net/http"
 )
 
+// RequestIDHeader carries the ID that ties a response to the server logs.
+// The RequestID middleware sets it on the response before any handler runs.
+const RequestIDHeader = "X-Request-ID"
+
+// Stable error codes. Clients should branch on these, never on the message.
+const (
+	ErrBadRequest           = "bad_request"
+	ErrUnauthorized         = "unauthorized"
+	ErrForbidden            = "forbidden"
+	ErrNotFound             = "not_found"
+	ErrMethodNotAllowed     = "method_not_allowed"
+	ErrConflict             = "conflict"
+	ErrGone                 = "gone"
+	ErrPreconditionFailed   = "precondition_failed"
+	ErrValidationFailed     = "validation_failed"
+	ErrInternal             = "internal_error"
+	ErrUpstream             = "upstream_error"
+	ErrInvalidToken         = "invalid_token"
+	ErrTokenRevoked         = "token_revoked"
+	ErrInsufficientScope    = "insufficient_scope"
+	ErrSessionRequired      = "session_required"
+	ErrProjectArchived      = "project_archived"
+	ErrVersionConflict      = "version_conflict"
+	ErrTransitionNotAllowed = "transition_not_allowed"
+	ErrWIPLimitExceeded     = "wip_limit_exceeded"
+	ErrChildrenOpen         = "children_open"
+	ErrTaskBlocked          = "task_blocked"
+	ErrDependencyCycle      = "dependency_cycle"
+)
+
+// defaultErrorCodes picks the code for errors sent without a specific one
+var defaultErrorCodes = map[int]string{
+	http.StatusBadRequest:          ErrBadRequest,
+	http.StatusUnauthorized:        ErrUnauthorized,
+	http.StatusForbidden:           ErrForbidden,
+	http.StatusNotFound:            ErrNotFound,
+	http.StatusMethodNotAllowed:    ErrMethodNotAllowed,
+	http.StatusConflict:            ErrConflict,
+	http.StatusGone:                ErrGone,
+	http.StatusPreconditionFailed:  ErrPreconditionFailed,
+	http.StatusUnprocessableEntity: ErrValidationFailed,
+	http.StatusInternalServerError: ErrInternal,
+	http.StatusBadGateway:          ErrUpstream,
+}
+
 // Response structure matching your request
 type APIResponse struct {
-	Status string      `json:"status"`
-	Desc   string      `json:"desc"`
-	Data   interface{} `json:"data,omitempty"`
+	Status    string      `json:"status"`
+	Desc      string      `json:"desc"`
+	Data      interface{} `json:"data,omitempty"`
+	Page      *Page       `json:"page,omitempty"`
+	RequestID string      `json:"requestId,omitempty"`
+}
+
+// Page tells a client of a list endpoint how to fetch the next page
+type Page struct {
+	Limit      int    `json:"limit"`
+	HasMore    bool   `json:"hasMore"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// Problem is the body of every error response: RFC 7807 problem details
+// with a stable code, the request ID and, for validation, the failing fields.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"requestId,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
 }
 
 // SendSuccess sends a standardized 200 OK response
 func SendSuccess(w http.ResponseWriter, desc string, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	SendJSON(w, http.StatusOK, desc, data)
+}
 
-	response := APIResponse{
-		Status: "Success",
-		Desc:   desc,
-		Data:   data,
-	}
-	json.NewEncoder(w).Encode(response)
+// SendCreated sends a standardized 201 Created response
+func SendCreated(w http.ResponseWriter, desc string, data interface{}) {
+	SendJSON(w, http.StatusCreated, desc, data)
 }
 
-// SendError sends a standardized error response
-func SendError(w http.ResponseWriter, code int, desc string) {
+// SendPage sends one page of a list; data stays the list itself
+func SendPage(w http.ResponseWriter, desc string, data interface{}, page Page) {
+	sendEnvelope(w, http.StatusOK, APIResponse{Desc: desc, Data: data, Page: &page})
+}
+
+// SendJSON sends a success envelope with the given status code
+func SendJSON(w http.ResponseWriter, code int, desc string, data interface{}) {
+	sendEnvelope(w, code, APIResponse{Desc: desc, Data: data})
+}
+
+func sendEnvelope(w http.ResponseWriter, code int, response APIResponse) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
 
-	response := APIResponse{
-		Status: "Error",
-		Desc:   desc,
-	}
+	response.Status = "Success"
+	response.RequestID = w.Header().Get(RequestIDHeader)
 	json.NewEncoder(w).Encode(response)
 }
+
+// SendError sends a problem+json error with the default code for the status
+func SendError(w http.ResponseWriter, code int, desc string) {
+	errorCode, ok := defaultErrorCodes[code]
+	if !ok {
+		errorCode = ErrInternal
+		if code < http.StatusInternalServerError {
+			errorCode = ErrBadRequest
+		}
+	}
+	SendErrorCode(w, code, errorCode, desc)
+}
+
+// SendErrorCode sends a problem+json error with a specific stable code
+func SendErrorCode(w http.ResponseWriter, code int, errorCode, desc string) {
+	sendProblem(w, Problem{Status: code, Code: errorCode, Detail: desc})
+}
+
+func sendProblem(w http.ResponseWriter, problem Problem) {
+	problem.Type = "/problems/" + problem.Code
+	problem.Title = http.StatusText(problem.Status)
+	problem.RequestID = w.Header().Get(RequestIDHeader)
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
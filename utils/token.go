@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// NewOpaqueToken returns a random URL-safe token with 256 bits of entropy
+func NewOpaqueToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HashToken returns the SHA-256 of an opaque token; only this hash is stored
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyPrefix starts every API key so it can be told apart from a JWT
+const APIKeyPrefix = "tlk_"
+
+// NewAPIKey returns a key of the form tlk_<lookup>_<secret> along with its lookup part
+func NewAPIKey() (key, lookup string, err error) {
+	buf := make([]byte, 6)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	lookup = hex.EncodeToString(buf)
+
+	secret, err := NewOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	return APIKeyPrefix + lookup + "_" + secret, lookup, nil
+}
+
+// SplitAPIKey returns the lookup part of an API key, or "" if it is malformed
+func SplitAPIKey(key string) string {
+	rest, ok := strings.CutPrefix(key, APIKeyPrefix)
+	if !ok {
+		return ""
+	}
+	lookup, _, ok := strings.Cut(rest, "_")
+	if !ok {
+		return ""
+	}
+	return lookup
+}
+
+// TokenHashMatches compares a token against a stored hash in constant time
+func TokenHashMatches(token, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashToken(token)), []byte(hash)) == 1
+}
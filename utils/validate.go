@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Machine-readable codes for a failing field
+const (
+	CodeRequired      = "required"
+	CodeTooShort      = "too_short"
+	CodeTooLong       = "too_long"
+	CodeInvalidChoice = "invalid_choice"
+	CodeInvalidFormat = "invalid_format"
+	CodeNotFound      = "not_found"
+	CodeNotAllowed    = "not_allowed"
+	CodeConflict      = "conflict"
+)
+
+// FieldError describes one invalid input field. Field uses the JSON name,
+// with an index for slice elements (e.g. "columns[1].name"). Position is set
+// for errors inside a parsed value, such as a filter expression.
+type FieldError struct {
+	Field    string `json:"field"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	Position int    `json:"position,omitempty"`
+}
+
+// ValidationErrors collects every failing field of a request
+type ValidationErrors []FieldError
+
+// Add records a failing field
+func (v *ValidationErrors) Add(field, code, message string) {
+	*v = append(*v, FieldError{Field: field, Code: code, Message: message})
+}
+
+// Has reports whether field already has an error
+func (v ValidationErrors) Has(field string) bool {
+	for _, e := range v {
+		if e.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks a struct against its `validate` tags and returns every failure.
+//
+// Supported rules, comma separated:
+//
+//	required      value must not be empty (strings are trimmed first)
+//	min=N, max=N  length for strings and slices, value for numbers
+//	oneof=a|b     value must be one of the listed ones (empty passes unless required)
+//	email         value must be an email address
+//	dive          validate a nested struct, or each struct element of a slice
+func Validate(v interface{}) ValidationErrors {
+	var errs ValidationErrors
+	validateStruct(reflect.ValueOf(v), "", &errs)
+	return errs
+}
+
+func validateStruct(value reflect.Value, prefix string, errs *ValidationErrors) {
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return
+	}
+
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" || !field.IsExported() {
+			continue
+		}
+		validateField(value.Field(i), prefix+jsonName(field), strings.Split(tag, ","), errs)
+	}
+}
+
+// jsonName is the name a field has in request bodies
+func jsonName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+func validateField(value reflect.Value, name string, rules []string, errs *ValidationErrors) {
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			// Optional fields in update requests are pointers; nil means "not sent"
+			return
+		}
+		value = value.Elem()
+	}
+
+	empty := isEmpty(value)
+	for _, rule := range rules {
+		key, arg, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			if empty {
+				errs.Add(name, CodeRequired, name+" is required")
+				return
+			}
+
+		case "min", "max":
+			limit, _ := strconv.Atoi(arg)
+			size, unit := measure(value)
+			if key == "min" && size < limit && !(empty && unit != "") {
+				errs.Add(name, CodeTooShort, strings.TrimSpace(fmt.Sprintf("%s must be at least %d %s", name, limit, unit)))
+				return
+			}
+			if key == "max" && size > limit {
+				errs.Add(name, CodeTooLong, strings.TrimSpace(fmt.Sprintf("%s must be at most %d %s", name, limit, unit)))
+				return
+			}
+
+		case "oneof":
+			if empty || value.Kind() != reflect.String {
+				continue
+			}
+			choices := strings.Split(arg, "|")
+			found := false
+			for _, c := range choices {
+				found = found || value.String() == c
+			}
+			if !found {
+				errs.Add(name, CodeInvalidChoice, name+" must be one of "+strings.Join(choices, ", "))
+				return
+			}
+
+		case "email":
+			if empty || value.Kind() != reflect.String {
+				continue
+			}
+			if addr, err := mail.ParseAddress(value.String()); err != nil || addr.Address != value.String() {
+				errs.Add(name, CodeInvalidFormat, name+" must be a valid email address")
+				return
+			}
+
+		case "dive":
+			if value.Kind() == reflect.Struct {
+				validateStruct(value, name+".", errs)
+				continue
+			}
+			if value.Kind() != reflect.Slice {
+				continue
+			}
+			for i := 0; i < value.Len(); i++ {
+				validateStruct(value.Index(i), fmt.Sprintf("%s[%d].", name, i), errs)
+			}
+		}
+	}
+}
+
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(value.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	default:
+		return value.IsZero()
+	}
+}
+
+// measure returns the size min/max compare against and, for lengths, its unit
+func measure(value reflect.Value) (int, string) {
+	switch value.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(value.String()), "characters"
+	case reflect.Slice, reflect.Map:
+		return value.Len(), "items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(value.Int()), ""
+	case reflect.Float32, reflect.Float64:
+		return int(value.Float()), ""
+	}
+	return 0, ""
+}
+
+// SendValidationErrors sends a 422 problem listing every failing field
+func SendValidationErrors(w http.ResponseWriter, errs ValidationErrors) {
+	sendProblem(w, Problem{
+		Status: http.StatusUnprocessableEntity,
+		Code:   ErrValidationFailed,
+		Detail: "One or more fields are invalid",
+		Errors: errs,
+	})
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type testItem struct {
+	Name string `json:"name" validate:"required,max=5"`
+}
+
+type testRequest struct {
+	Title    string     `json:"title" validate:"required,max=10"`
+	Nick     string     `json:"nick" validate:"min=3"`
+	Priority string     `json:"priority" validate:"oneof=Low|High"`
+	Email    string     `json:"email" validate:"email"`
+	Count    int        `json:"count" validate:"min=1,max=3"`
+	Tags     []string   `json:"tags" validate:"max=2"`
+	Items    []testItem `json:"items" validate:"dive"`
+	Nested   *testItem  `json:"nested" validate:"dive"`
+	Limit    *int       `json:"limit" validate:"required"`
+	NoJSON   string     `validate:"required"`
+	ignored  string     `validate:"required"`
+}
+
+func validRequest() testRequest {
+	limit := 1
+	return testRequest{Title: "ok", Count: 1, Limit: &limit, NoJSON: "x"}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*testRequest)
+		want   []string // field:code of every error, in order
+	}{
+		{"valid", func(r *testRequest) {}, nil},
+		{"required", func(r *testRequest) { r.Title = "" }, []string{"title:required"}},
+		{"required trims spaces", func(r *testRequest) { r.Title = "   " }, []string{"title:required"}},
+		{"max counts characters, not bytes", func(r *testRequest) { r.Title = "ééééééééé" }, nil},
+		{"max on strings", func(r *testRequest) { r.Title = "12345678901" }, []string{"title:too_long"}},
+		{"min skips empty strings", func(r *testRequest) { r.Nick = "" }, nil},
+		{"min on strings", func(r *testRequest) { r.Nick = "ab" }, []string{"nick:too_short"}},
+		{"oneof", func(r *testRequest) { r.Priority = "Medium" }, []string{"priority:invalid_choice"}},
+		{"oneof lets empty pass", func(r *testRequest) { r.Priority = "" }, nil},
+		{"email", func(r *testRequest) { r.Email = "not-an-email" }, []string{"email:invalid_format"}},
+		{"email with a display name", func(r *testRequest) { r.Email = "Bob <bob@example.com>" }, []string{"email:invalid_format"}},
+		{"numbers compare by value", func(r *testRequest) { r.Count = 4 }, []string{"count:too_long"}},
+		{"zero number is below min", func(r *testRequest) { r.Count = 0 }, []string{"count:too_short"}},
+		{"max on slices", func(r *testRequest) { r.Tags = []string{"a", "b", "c"} }, []string{"tags:too_long"}},
+		{"dive into slices", func(r *testRequest) { r.Items = []testItem{{Name: "ok"}, {Name: ""}, {Name: "toolong"}} },
+			[]string{"items[1].name:required", "items[2].name:too_long"}},
+		{"dive into pointers", func(r *testRequest) { r.Nested = &testItem{} }, []string{"nested.name:required"}},
+		{"nil pointers are not sent", func(r *testRequest) { r.Limit = nil }, nil},
+		{"fields without a json name use the Go name", func(r *testRequest) { r.NoJSON = "" }, []string{"NoJSON:required"}},
+		{"every failure is reported", func(r *testRequest) { r.Title = ""; r.Priority = "x"; r.Count = 9 },
+			[]string{"title:required", "priority:invalid_choice", "count:too_long"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := validRequest()
+			tt.modify(&r)
+			var got []string
+			for _, e := range Validate(r) {
+				got = append(got, e.Field+":"+e.Code)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePasswordField(t *testing.T) {
+	tests := []struct {
+		password string
+		want     string
+	}{
+		{"short", CodeTooShort},
+		{"12345678", ""},
+		{string(make([]byte, 72)), ""},
+		{string(make([]byte, 73)), CodeTooLong},
+	}
+	for _, tt := range tests {
+		var errs ValidationErrors
+		ValidatePasswordField(&errs, "password", tt.password)
+		got := ""
+		if len(errs) > 0 {
+			got = errs[0].Code
+		}
+		if got != tt.want {
+			t.Errorf("ValidatePasswordField(%d bytes) = %q, want %q", len(tt.password), got, tt.want)
+		}
+	}
+}
+
+func TestSendValidationErrors(t *testing.T) {
+	var errs ValidationErrors
+	errs.Add("title", CodeRequired, "title is required")
+	w := httptest.NewRecorder()
+	SendValidationErrors(w, errs)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422", w.Code)
+	}
+	var body struct {
+		Code   string       `json:"code"`
+		Errors []FieldError `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body.Code != ErrValidationFailed || len(body.Errors) != 1 || body.Errors[0].Field != "title" {
+		t.Errorf("body = %+v", body)
+	}
+}
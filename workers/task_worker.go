@@ -8,6 +8,7 @@ import (
 	"trello-lite/models"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func StartOverdueScanner() {
@@ -26,9 +27,10 @@ func scanForOverdueTasks() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	filter := bson.M{
-		"status":  bson.M{"$ne": "Done"},
-		"duedate": bson.M{"$lt": time.Now()},
+	filter, err := overdueFilter(ctx)
+	if err != nil {
+		fmt.Println("Worker Error:", err)
+		return
 	}
 
 	cursor, err := collection.Find(ctx, filter)
@@ -50,3 +52,41 @@ func scanForOverdueTasks() {
 		}
 	}
 }
+
+// overdueFilter matches past-due tasks that are not in one of their project's
+// done statuses. Projects without a custom workflow use the default one.
+func overdueFilter(ctx context.Context) (bson.M, error) {
+	projColl := databases.GetCollection(databases.Client, "projects")
+	cursor, err := projColl.Find(ctx,
+		bson.M{"workflow.statuses": bson.M{"$exists": true, "$ne": bson.A{}}},
+		options.Find().SetProjection(bson.M{"workflow": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var projects []models.Project
+	if err := cursor.All(ctx, &projects); err != nil {
+		return nil, err
+	}
+
+	clauses := []bson.M{}
+	custom := []string{}
+	for _, p := range projects {
+		custom = append(custom, p.ID)
+		clauses = append(clauses, bson.M{
+			"projectid": p.ID,
+			"status":    bson.M{"$nin": p.EffectiveWorkflow().DoneStatuses()},
+		})
+	}
+	clauses = append(clauses, bson.M{
+		"projectid": bson.M{"$nin": custom},
+		"status":    bson.M{"$nin": models.DefaultWorkflow().DoneStatuses()},
+	})
+
+	return bson.M{
+		"duedate": bson.M{"$lt": time.Now()},
+		"$or":     clauses,
+	}, nil
+}